@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeys checks the keys of API requests. An empty key list disables
+// authentication.
+type APIKeys []string
+
+func (k APIKeys) Valid(key string) bool {
+	if len(k) == 0 {
+		return true
+	}
+	ok := 0
+	for _, want := range k {
+		ok |= subtle.ConstantTimeCompare([]byte(key), []byte(want))
+	}
+	return ok == 1
+}
+
+// requestKey gets the key from "Authorization: Bearer <key>" or
+// "X-API-Key: <key>".
+func requestKey(authorization string, apiKey string) string {
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+	return apiKey
+}
+
+// GinMiddleware rejects requests without a valid key. Browsers cannot set
+// headers on web sockets so the key is also accepted as api_key query.
+func (k APIKeys) GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := requestKey(c.GetHeader("Authorization"), c.GetHeader("X-API-Key"))
+		if key == "" {
+			key = c.Query("api_key")
+		}
+		if !k.Valid(key) {
+			c.AbortWithStatusJSON(401, gin.H{
+				"Error": "Unauthorized",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (k APIKeys) checkContext(ctx context.Context) error {
+	if len(k) == 0 {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(name string) string {
+		if vals := md.Get(name); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+	if !k.Valid(requestKey(first("authorization"), first("x-api-key"))) {
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return nil
+}
+
+func (k APIKeys) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := k.checkContext(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (k APIKeys) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := k.checkContext(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+const (
+	LlamaBackendName = "llama"
+	FakeBackendName  = "fake"
+)
+
+// Backend runs the jobs of a worker. The llama backend runs the model,
+// the fake backend generates scripted tokens for tests.
+type Backend interface {
+	Load() error
+	Predict(ctx context.Context, params llama.PredictParams, text string, cb llama.WordCallbackFn) (llama.PredictResult, error)
+	Tokenize(prompt string) ([]string, error)
+	Embed(text string) ([]float32, error)
+	Perplexity(text string, cb llama.PerplexityCallbackFn) (float64, error)
+	Info() BackendInfo
+}
+
+type BackendInfo struct {
+	Backend string
+	Path    string
+	CtxSize int
+	Threads int
+}
+
+// NewBackend creates the backend of the given name, empty name is the
+// llama backend. The backend is not loaded yet.
+func NewBackend(name string, path string, nctx int, threads int, nParts int) (Backend, error) {
+	switch name {
+	case "", LlamaBackendName:
+		return &llamaBackend{llama.NewModel(path, nctx, threads, nParts)}, nil
+	case FakeBackendName:
+		return NewFakeBackend(path, nctx, threads), nil
+	}
+	return nil, fmt.Errorf("Unknown backend %q", name)
+}
+
+func validBackend(name string) bool {
+	return name == "" || name == LlamaBackendName || name == FakeBackendName
+}
+
+type llamaBackend struct {
+	*llama.Model
+}
+
+func (b *llamaBackend) Predict(ctx context.Context, params llama.PredictParams, text string, cb llama.WordCallbackFn) (llama.PredictResult, error) {
+	return b.PredictContext(ctx, params, text, cb)
+}
+
+func (b *llamaBackend) Info() BackendInfo {
+	return BackendInfo{
+		Backend: LlamaBackendName,
+		Path:    b.Path(),
+		CtxSize: b.ContextSize(),
+		Threads: b.Threads(),
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+// BatchRequest is one line of the batch input. Requests without id get
+// their line number as id.
+type BatchRequest struct {
+	ID json.RawMessage `json:"id,omitempty"`
+	CompletionParams
+}
+
+// BatchResult is one line of the batch output.
+type BatchResult struct {
+	ID     json.RawMessage `json:"id"`
+	Text   string          `json:"text"`
+	Usage  llama.Usage     `json:"usage"`
+	Reason string          `json:"reason"`
+	Error  string          `json:"error,omitempty"`
+	// ContextShifts is the number of times the context was shifted
+	ContextShifts int `json:"context_shifts,omitempty"`
+}
+
+// BatchRunFn generates the completion of one request.
+type BatchRunFn func(ctx context.Context, params *CompletionParams) (BatchResult, error)
+
+// Batch processes a JSONL file of completion requests. The output file is
+// the checkpoint: requests whose id is already in it are skipped, so an
+// interrupted batch continues where it stopped.
+type Batch struct {
+	Input       string
+	Output      string
+	Concurrency int
+	Tokens      int
+	Defaults    CompletionDefaults
+	Limits      Limits
+	Run         BatchRunFn
+
+	out *os.File
+}
+
+type batchItem struct {
+	id     json.RawMessage
+	params *CompletionParams
+}
+
+// batchKey normalizes the id so that resume matches ids written with
+// other spacing.
+func batchKey(id json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, id) != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+// loadCheckpoint returns the ids in the output file. An incomplete last
+// line left by a crash is cut off.
+func (b *Batch) loadCheckpoint() (map[string]bool, error) {
+	done := map[string]bool{}
+	data, err := os.ReadFile(b.Output)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		log.Printf("Drop incomplete last line of %s", b.Output)
+		err = os.Truncate(b.Output, int64(complete))
+		if err != nil {
+			return nil, err
+		}
+		data = data[:complete]
+	}
+	for i, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		res := BatchResult{}
+		err = json.Unmarshal(line, &res)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid line %d in %s: %w", i+1, b.Output, err)
+		}
+		done[batchKey(res.ID)] = true
+	}
+	return done, nil
+}
+
+func (b *Batch) write(res BatchResult) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	// One write per line, a crash can only leave the last line incomplete
+	_, err = b.out.Write(data)
+	if err != nil {
+		return err
+	}
+	return b.out.Sync()
+}
+
+// Process runs all requests that are not in the output file yet. When ctx
+// is done no new requests are started and the running ones are finished.
+func (b *Batch) Process(ctx context.Context) error {
+	done, err := b.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(b.Input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	b.out, err = os.OpenFile(b.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer b.out.Close()
+
+	if b.Concurrency <= 0 {
+		b.Concurrency = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		nDone    int
+		nErr     int
+		writeErr error
+	)
+	record := func(res BatchResult) {
+		lock.Lock()
+		defer lock.Unlock()
+		if err := b.write(res); err != nil && writeErr == nil {
+			writeErr = err
+		}
+		nDone++
+		if res.Error != "" {
+			nErr++
+			log.Printf("[%d] id %s error: %s", nDone, res.ID, res.Error)
+		} else {
+			log.Printf("[%d] id %s: %d tokens, %s", nDone, res.ID, res.Usage.CompletionTokens, res.Reason)
+		}
+	}
+	items := make(chan batchItem)
+	for i := 0; i < b.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				res, err := b.Run(ctx, item.params)
+				if ctx.Err() != nil && err != nil {
+					// Interrupted, keep it for the next run
+					continue
+				}
+				res.ID = item.id
+				if err != nil {
+					res.Error = err.Error()
+				}
+				record(res)
+			}
+		}()
+	}
+
+	skipped := 0
+	seen := map[string]bool{}
+	reader := bufio.NewReader(in)
+	lineNo := 0
+	var readErr error
+	for ctx.Err() == nil {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+		lineNo++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		req := &BatchRequest{CompletionParams: b.defaultParams()}
+		perr := json.Unmarshal(line, req)
+		id := req.ID
+		if len(id) == 0 || perr != nil {
+			id = json.RawMessage(strconv.Itoa(lineNo))
+		}
+		key := batchKey(id)
+		if seen[key] {
+			readErr = fmt.Errorf("Duplicate id %s at line %d", id, lineNo)
+			break
+		}
+		seen[key] = true
+		if done[key] {
+			skipped++
+			continue
+		}
+		if perr != nil {
+			record(BatchResult{ID: id, Error: "Invalid request: " + perr.Error()})
+			continue
+		}
+		params := &req.CompletionParams
+		if err := validateCompletion(params, b.Defaults.SamplerPresets, b.Limits); err != nil {
+			record(BatchResult{ID: id, Error: err.Error()})
+			continue
+		}
+		select {
+		case items <- batchItem{id: id, params: params}:
+		case <-ctx.Done():
+		}
+	}
+	close(items)
+	wg.Wait()
+	log.Printf("Batch finished: %d processed, %d errors, %d skipped from previous runs", nDone, nErr, skipped)
+	if readErr != nil {
+		return readErr
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return ctx.Err()
+}
+
+// defaultParams returns the parameters of a request that sets none, the
+// lines of the input are unmarshalled over them.
+func (b *Batch) defaultParams() CompletionParams {
+	params := CompletionParams{
+		Tokens:        b.Tokens,
+		TopK:          40,
+		TopP:          0.95,
+		Temp:          0.8,
+		RepeatPenalty: 1.3,
+		RepeatLastN:   64,
+	}
+	b.Defaults.Apply(&params)
+	return params
+}
+
+// ModelBatchRun runs the requests on an in-process backend.
+func ModelBatchRun(backend Backend, seed int) BatchRunFn {
+	var lock sync.Mutex
+	return func(ctx context.Context, params *CompletionParams) (BatchResult, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		var text strings.Builder
+		result, err := backend.Predict(ctx, params.ToPredictParams(seed), params.Prompt, func(word string) {
+			text.WriteString(word)
+		})
+		if result.Reason == llama.PROMPT_CANCEL {
+			return BatchResult{}, ctx.Err()
+		}
+		ret := BatchResult{
+			Text:   strings.ToValidUTF8(text.String(), string(utf8.RuneError)),
+			Usage:  result.Usage,
+			Reason: result.Reason.String(),
+
+			ContextShifts: result.ContextShifts,
+		}
+		return ret, err
+	}
+}
+
+// WorkerBatchRun runs the requests on the worker processes of wm.
+func WorkerBatchRun(wm *WorkerManager, seed int) BatchRunFn {
+	return func(ctx context.Context, params *CompletionParams) (BatchResult, error) {
+		job := NewJob(CompletionJob, params.Prompt, params.ToPredictParams(seed))
+		wm.DispatchJob(job)
+		var text strings.Builder
+		for words := range job.Response {
+			text.WriteString(strings.Join(words, ""))
+		}
+		ret := BatchResult{
+			Text:   text.String(),
+			Usage:  job.Usage,
+			Reason: job.Reason,
+
+			ContextShifts: job.ContextShifts,
+		}
+		return ret, job.Err
+	}
+}
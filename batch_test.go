@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBatchRequestDefaults(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.jsonl")
+	lines := `{"id": 1, "prompt": "a"}
+{"id": 2, "prompt": "b", "repeat_lastn": 0, "tokens": 3}
+{"id": 3, "prompt": "c", "temp": 0, "top_k": 0}
+`
+	if err := os.WriteFile(input, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	temp := float32(0.5)
+	var lock sync.Mutex
+	got := map[string]CompletionParams{}
+	b := &Batch{
+		Input:    input,
+		Output:   filepath.Join(dir, "out.jsonl"),
+		Tokens:   16,
+		Defaults: CompletionDefaults{Temp: &temp},
+		Run: func(ctx context.Context, params *CompletionParams) (BatchResult, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			got[params.Prompt] = *params
+			return BatchResult{Reason: "Stop"}, nil
+		},
+	}
+	if err := b.Process(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := got["a"]; p.Temp != 0.5 || p.TopK != 40 || p.RepeatLastN != 64 || p.Tokens != 16 {
+		t.Errorf("defaults = %+v", p)
+	}
+	if p := got["b"]; p.Temp != 0.5 || p.RepeatLastN != 0 || p.Tokens != 3 || p.TopP != 0.95 {
+		t.Errorf("explicit zeros = %+v", p)
+	}
+	if _, ok := got["c"]; ok {
+		t.Error("invalid zero temp and top_k forwarded")
+	}
+	out, err := os.ReadFile(b.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		var res BatchResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatal(err)
+		}
+		errs[string(res.ID)] = res.Error
+	}
+	if len(errs) != 3 || errs["1"] != "" || errs["2"] != "" || errs["3"] != "Invalid temp 0, must be positive" {
+		t.Errorf("errors = %q", errs)
+	}
+}
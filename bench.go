@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+type BenchOptions struct {
+	PromptLengths []int
+	GenLengths    []int
+	Threads       []int
+	BatchSizes    []int
+	Repeat        int
+	JSON          bool
+}
+
+type BenchResult struct {
+	Threads           int     `json:"threads"`
+	Batch             int     `json:"batch"`
+	PromptTokens      int     `json:"prompt_tokens"`
+	GenTokens         int     `json:"gen_tokens"`
+	PromptTokensPerS  float64 `json:"prompt_tokens_per_second"`
+	GenTokensPerS     float64 `json:"gen_tokens_per_second"`
+	FirstTokenMs      float64 `json:"first_token_ms"`
+	MemPerToken       int     `json:"mem_per_token"`
+	SampleMsPerToken  float64 `json:"sample_ms_per_token"`
+	PredictMsPerToken float64 `json:"predict_ms_per_token"`
+}
+
+// parseIntList parses a comma separated list of positive numbers.
+func parseIntList(s string) ([]int, error) {
+	ret := []int{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %q", item)
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("Number must be positive, got %d", n)
+		}
+		ret = append(ret, n)
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("Empty list")
+	}
+	return ret, nil
+}
+
+// benchPrompt builds a prompt that tokenizes to about n tokens.
+func benchPrompt(n int) string {
+	// One token is the BOS token, predict adds the leading space
+	if n > 1 {
+		return strings.TrimSpace(strings.Repeat("the ", n-1))
+	}
+	return ""
+}
+
+// RunBench runs every combination of the options on the model. The first
+// run is a warm up and is not reported.
+func RunBench(model *llama.Model, nctx int, opts BenchOptions, w io.Writer) error {
+	if opts.Repeat <= 0 {
+		opts.Repeat = 1
+	}
+	warmup := llama.DefaultPredictParams(1)
+	warmup.Threads = opts.Threads[0]
+	_, err := model.Predict(warmup, benchPrompt(8), func(string) {})
+	if err != nil {
+		return err
+	}
+
+	var tw *tabwriter.Writer
+	if !opts.JSON {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "threads\tbatch\tprompt\tgen\tprompt tok/s\tgen tok/s\tfirst token ms\tmem/token\t")
+		defer tw.Flush()
+	}
+	enc := json.NewEncoder(w)
+	for _, threads := range opts.Threads {
+		for _, batch := range opts.BatchSizes {
+			for _, plen := range opts.PromptLengths {
+				for _, glen := range opts.GenLengths {
+					if plen+glen > nctx {
+						log.Printf("Skip prompt %d + gen %d, exceeds context size %d", plen, glen, nctx)
+						continue
+					}
+					res, err := benchOne(model, threads, batch, plen, glen, opts.Repeat)
+					if err != nil {
+						return err
+					}
+					if opts.JSON {
+						enc.Encode(res)
+						continue
+					}
+					fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%.2f\t%.2f\t%.2f\t%s\t\n",
+						res.Threads, res.Batch, res.PromptTokens, res.GenTokens,
+						res.PromptTokensPerS, res.GenTokensPerS, res.FirstTokenMs,
+						formatBytes(res.MemPerToken))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func benchOne(model *llama.Model, threads, batch, plen, glen, repeat int) (BenchResult, error) {
+	ret := BenchResult{
+		Threads: threads,
+		Batch:   batch,
+	}
+	params := llama.DefaultPredictParams(glen)
+	params.Threads = threads
+	params.NBatch = batch
+	params.IgnoreEOS = true
+	prompt := benchPrompt(plen)
+	var promptMs, genPerS float64
+	for i := 0; i < repeat; i++ {
+		res, err := model.Predict(params, prompt, func(string) {})
+		if err != nil {
+			return ret, err
+		}
+		ret.PromptTokens = res.Usage.PromptTokens
+		ret.GenTokens = res.Usage.CompletionTokens
+		ret.MemPerToken = res.MemPerToken
+		promptMs += res.Timing.PromptEvalMs
+		genPerS += res.Timing.TokensPerSecond
+		ret.FirstTokenMs += res.Timing.FirstTokenMs / float64(repeat)
+		ret.SampleMsPerToken += res.Timing.SampleMsPerToken / float64(repeat)
+		ret.PredictMsPerToken += res.Timing.PredictMsPerToken / float64(repeat)
+	}
+	if promptMs > 0 {
+		ret.PromptTokensPerS = float64(ret.PromptTokens*repeat) * 1000 / promptMs
+	}
+	ret.GenTokensPerS = genPerS / float64(repeat)
+	return ret, nil
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
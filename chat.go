@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorPrompt = "\x1b[33m"
+	colorInput  = "\x1b[1;32m"
+	colorInfo   = "\x1b[36m"
+)
+
+const chatHelp = `Commands:
+  /reset                 forget the conversation
+  /params [key=value...] show or set tokens, temp, top_k, top_p, repeat_penalty, repeat_lastn,
+                         mirostat, mirostat_tau, mirostat_eta, min_p, typical_p, tfs_z,
+                         frequency_penalty, presence_penalty,
+                         samplers (comma separated chain, empty for the default)
+  /save FILE             save the transcript to FILE
+  /quit                  exit, same as Ctrl+D
+Ctrl+C stops the running generation.`
+
+// Chat is an interactive conversation with an in-process model. Each turn
+// sends the whole transcript to the model, generation stops when the model
+// writes the reverse prompt.
+type Chat struct {
+	Model       *llama.Model
+	Params      CompletionParams
+	Seed        int
+	Reverse     string
+	HistoryFile string
+	Color       bool
+
+	system string
+	turns  []string
+	in     *bufio.Reader
+	out    io.Writer
+	lock   sync.Mutex
+	cancel context.CancelFunc
+}
+
+func NewChat(model *llama.Model, prompt string, reverse string, params CompletionParams, seed int) *Chat {
+	system := strings.TrimRight(prompt, " \r\n")
+	if !strings.HasSuffix(system, reverse) {
+		if system != "" {
+			system += "\n"
+		}
+		system += reverse
+	}
+	return &Chat{
+		Model:   model,
+		Params:  params,
+		Seed:    seed,
+		Reverse: reverse,
+		system:  system,
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+	}
+}
+
+// isTerminal reports whether f is a terminal, used to enable colors.
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return st.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *Chat) setColor(code string) {
+	if c.Color {
+		fmt.Fprint(c.out, code)
+	}
+}
+
+func (c *Chat) info(format string, args ...any) {
+	c.setColor(colorInfo)
+	fmt.Fprintf(c.out, format, args...)
+	c.setColor(colorReset)
+	fmt.Fprintln(c.out)
+}
+
+func (c *Chat) transcript() string {
+	return c.system + strings.Join(c.turns, "")
+}
+
+func (c *Chat) loadHistory() error {
+	if c.HistoryFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.HistoryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.turns)
+}
+
+func (c *Chat) saveHistory() error {
+	if c.HistoryFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.turns)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.HistoryFile, data, 0600)
+}
+
+func (c *Chat) Run() error {
+	err := c.loadHistory()
+	if err != nil {
+		return fmt.Errorf("Cannot load chat history: %w", err)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	go func() {
+		for range sigCh {
+			c.lock.Lock()
+			if c.cancel != nil {
+				c.cancel()
+			} else {
+				fmt.Fprintln(c.out)
+				c.info("Use /quit or Ctrl+D to exit")
+				fmt.Fprint(c.out, c.Reverse)
+			}
+			c.lock.Unlock()
+		}
+	}()
+
+	c.info("Type /help for commands")
+	c.setColor(colorPrompt)
+	fmt.Fprint(c.out, c.transcript())
+	c.setColor(colorReset)
+	for {
+		c.setColor(colorInput)
+		line, err := c.in.ReadString('\n')
+		c.setColor(colorReset)
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(c.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "/") {
+			quit := c.command(line)
+			if quit {
+				return nil
+			}
+			fmt.Fprint(c.out, c.Reverse)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			fmt.Fprint(c.out, c.Reverse)
+			continue
+		}
+		err = c.reply(" " + line + "\n")
+		if err != nil {
+			c.info("Error: %v", err)
+			fmt.Fprint(c.out, c.Reverse)
+		}
+	}
+}
+
+// fit drops the oldest turns until the prompt and the generated tokens fit
+// into the context.
+func (c *Chat) fit(turn string) error {
+	for {
+		prompt := c.transcript() + turn
+		tokens, err := c.Model.Tokenize(prompt)
+		if err != nil {
+			return err
+		}
+		n := len(tokens)
+		if n+c.Params.Tokens <= c.Model.ContextSize() {
+			return nil
+		}
+		if len(c.turns) == 0 {
+			return fmt.Errorf("Prompt of %d tokens and %d generated tokens exceed the context size %d", n, c.Params.Tokens, c.Model.ContextSize())
+		}
+		c.turns = c.turns[1:]
+		c.info("(dropped the oldest turn to fit the context)")
+	}
+}
+
+func (c *Chat) reply(turn string) error {
+	err := c.fit(turn)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.lock.Lock()
+	c.cancel = cancel
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		c.cancel = nil
+		c.lock.Unlock()
+		cancel()
+	}()
+
+	var (
+		reply  strings.Builder
+		buffer strings.Builder
+	)
+	pp := c.Params.ToPredictParams(c.Seed)
+	_, err = c.Model.PredictContext(ctx, pp, c.transcript()+turn, func(word string) {
+		buffer.WriteString(word)
+		if !utf8.ValidString(buffer.String()) {
+			return
+		}
+		fmt.Fprint(c.out, buffer.String())
+		reply.WriteString(buffer.String())
+		buffer.Reset()
+		if strings.HasSuffix(reply.String(), c.Reverse) {
+			cancel()
+		}
+	})
+	text := reply.String() + buffer.String()
+	if !strings.HasSuffix(text, c.Reverse) {
+		// Stopped by length, end of text or Ctrl+C
+		text += "\n" + c.Reverse
+		fmt.Fprint(c.out, "\n"+c.Reverse)
+	}
+	c.turns = append(c.turns, turn+text)
+	if serr := c.saveHistory(); serr != nil {
+		c.info("Cannot save chat history: %v", serr)
+	}
+	return err
+}
+
+// command runs a slash command and returns true to quit.
+func (c *Chat) command(line string) bool {
+	args := strings.Fields(line)
+	switch args[0] {
+	case "/quit", "/exit":
+		return true
+	case "/help":
+		c.info(chatHelp)
+	case "/reset":
+		c.turns = []string{}
+		if err := c.saveHistory(); err != nil {
+			c.info("Cannot save chat history: %v", err)
+		}
+		c.info("Conversation reset")
+		c.setColor(colorPrompt)
+		fmt.Fprint(c.out, strings.TrimSuffix(c.system, c.Reverse))
+		c.setColor(colorReset)
+	case "/params":
+		for _, arg := range args[1:] {
+			err := c.setParam(arg)
+			if err != nil {
+				c.info("%v", err)
+			}
+		}
+		p := c.Params
+		c.info("tokens=%d temp=%v top_k=%d top_p=%v repeat_penalty=%v repeat_lastn=%d mirostat=%d mirostat_tau=%v mirostat_eta=%v min_p=%v typical_p=%v tfs_z=%v frequency_penalty=%v presence_penalty=%v samplers=%s",
+			p.Tokens, p.Temp, p.TopK, p.TopP, p.RepeatPenalty, p.RepeatLastN, p.Mirostat, p.MirostatTau, p.MirostatEta, p.MinP, p.TypicalP, p.TfsZ,
+			p.FrequencyPenalty, p.PresencePenalty, strings.Join(p.Samplers, ","))
+	case "/save":
+		if len(args) != 2 {
+			c.info("Usage: /save FILE")
+			break
+		}
+		err := os.WriteFile(args[1], []byte(c.transcript()), 0644)
+		if err != nil {
+			c.info("Cannot save transcript: %v", err)
+			break
+		}
+		c.info("Transcript saved to %s", args[1])
+	default:
+		c.info("Unknown command %s, type /help for commands", args[0])
+	}
+	return false
+}
+
+func (c *Chat) setParam(arg string) error {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return fmt.Errorf("Invalid parameter %q, use key=value", arg)
+	}
+	p := c.Params
+	var err error
+	switch key {
+	case "tokens":
+		p.Tokens, err = strconv.Atoi(value)
+	case "top_k":
+		p.TopK, err = strconv.Atoi(value)
+	case "repeat_lastn":
+		p.RepeatLastN, err = strconv.Atoi(value)
+	case "temp":
+		p.Temp, err = parseFloat32(value)
+	case "top_p":
+		p.TopP, err = parseFloat32(value)
+	case "repeat_penalty":
+		p.RepeatPenalty, err = parseFloat32(value)
+	case "mirostat":
+		p.Mirostat, err = strconv.Atoi(value)
+	case "mirostat_tau":
+		p.MirostatTau, err = parseFloat32(value)
+	case "mirostat_eta":
+		p.MirostatEta, err = parseFloat32(value)
+	case "min_p":
+		p.MinP, err = parseFloat32(value)
+	case "typical_p":
+		p.TypicalP, err = parseFloat32(value)
+	case "tfs_z":
+		p.TfsZ, err = parseFloat32(value)
+	case "frequency_penalty":
+		p.FrequencyPenalty, err = parseFloat32(value)
+	case "presence_penalty":
+		p.PresencePenalty, err = parseFloat32(value)
+	case "samplers":
+		p.Samplers = nil
+		if value != "" {
+			p.Samplers = strings.Split(value, ",")
+		}
+	default:
+		return fmt.Errorf("Unknown parameter %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid value of %s: %w", key, err)
+	}
+	if p.Tokens <= 0 {
+		return errors.New("tokens must be positive")
+	}
+	// the prompt is the conversation, validate the parameters alone
+	check := p
+	check.Prompt = "chat"
+	if err := validateCompletion(&check, nil, Limits{}); err != nil {
+		return err
+	}
+	c.Params = p
+	return nil
+}
+
+func parseFloat32(s string) (float32, error) {
+	v, err := strconv.ParseFloat(s, 32)
+	return float32(v), err
+}
@@ -3,4 +3,4 @@
 # Temporary script - will be removed in the future
 #
 
-./main -m ./models/7B/ggml-model-q4_0.bin -n 256 --repeat_penalty 1.0 --color -i -r "User:" -f prompts/chat-with-bob.txt
+./llama-go -M chat -m ./models/7B/ggml-model-q4_0.bin -tokens 256 -repeat-penalty 1.0 -r "User:" -f prompts/chat-with-bob.txt
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestChatSetParam(t *testing.T) {
+	c := &Chat{Params: CompletionParams{Tokens: 16, TopK: 40, TopP: 0.95, Temp: 0.8, RepeatPenalty: 1.3, RepeatLastN: 64}}
+	if err := c.setParam("temp=0.5"); err != nil || c.Params.Temp != 0.5 {
+		t.Fatalf("temp=0.5: err = %v, temp = %v", err, c.Params.Temp)
+	}
+	for _, arg := range []string{"temp=0", "top_k=0", "top_p=2", "min_p=2", "frequency_penalty=3", "mirostat=3", "samplers=top_k=x", "tokens=0", "unknown=1", "temp"} {
+		if err := c.setParam(arg); err == nil {
+			t.Errorf("%s: want error", arg)
+		}
+	}
+	if c.Params.Temp != 0.5 || c.Params.TopK != 40 || c.Params.Tokens != 16 {
+		t.Errorf("params changed by invalid values: %+v", c.Params)
+	}
+}
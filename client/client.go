@@ -0,0 +1,321 @@
+// Package client is a Go client for the llama-go HTTP and WebSocket API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CompletionRequest is the body of /api/completion. Nil pointers and
+// other zero values use the server defaults, the pointers allow explicit
+// zeros like RepeatLastN: client.Int(0) to disable the repeat penalty.
+// TopK, TopP, Temp and RepeatPenalty must be positive, TopK: client.Int(1)
+// samples greedily.
+type CompletionRequest struct {
+	Model         string   `json:"model,omitempty"`
+	Prompt        string   `json:"prompt"`
+	Tokens        int      `json:"tokens"`
+	TopK          *int     `json:"top_k,omitempty"`
+	RepeatLastN   *int     `json:"repeat_lastn,omitempty"`
+	TopP          *float32 `json:"top_p,omitempty"`
+	Temp          *float32 `json:"temp,omitempty"`
+	RepeatPenalty *float32 `json:"repeat_penalty,omitempty"`
+	Mirostat      *int     `json:"mirostat,omitempty"`
+	MirostatTau   *float32 `json:"mirostat_tau,omitempty"`
+	MirostatEta   *float32 `json:"mirostat_eta,omitempty"`
+	MinP          *float32 `json:"min_p,omitempty"`
+	TypicalP      *float32 `json:"typical_p,omitempty"`
+	TfsZ          *float32 `json:"tfs_z,omitempty"`
+
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	// PenaltyExclude are token ids that are not penalized, like newline
+	PenaltyExclude []int `json:"penalty_exclude,omitempty"`
+
+	// NumBeams above one runs a beam search instead of sampling
+	NumBeams       int      `json:"num_beams,omitempty"`
+	NumReturnBeams int      `json:"num_return_beams,omitempty"`
+	LengthPenalty  *float32 `json:"length_penalty,omitempty"`
+	EarlyStopping  bool     `json:"early_stopping,omitempty"`
+
+	// CFGScale above one steers the generation away from NegativePrompt
+	// with classifier-free guidance
+	NegativePrompt string  `json:"negative_prompt,omitempty"`
+	CFGScale       float32 `json:"cfg_scale,omitempty"`
+
+	// Instruct wraps the prompt in the "### Instruction:" and "### Response:"
+	// markers of Alpaca models and stops at the next instruction
+	Instruct *bool `json:"instruct,omitempty"`
+
+	// ContextShift keeps generating once the context is full, the first
+	// ContextKeep tokens and the most recent half of the others are kept
+	ContextShift bool `json:"context_shift,omitempty"`
+	ContextKeep  int  `json:"context_keep,omitempty"`
+
+	// Samplers is the ordered sampler chain like ["top_k=20", "temp"],
+	// SamplerPreset selects a chain configured on the server instead
+	Samplers      []string `json:"samplers,omitempty"`
+	SamplerPreset string   `json:"sampler_preset,omitempty"`
+	Stream        bool     `json:"stream,omitempty"`
+}
+
+// Int returns a pointer to v for the optional fields of a request.
+func Int(v int) *int {
+	return &v
+}
+
+// Float32 returns a pointer to v for the optional fields of a request.
+func Float32(v float32) *float32 {
+	return &v
+}
+
+// Bool returns a pointer to v for the optional fields of a request.
+func Bool(v bool) *bool {
+	return &v
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type Timing struct {
+	QueueMs           float64 `json:"queue_ms"`
+	PromptEvalMs      float64 `json:"prompt_eval_ms"`
+	SampleMsPerToken  float64 `json:"sample_ms_per_token"`
+	PredictMsPerToken float64 `json:"predict_ms_per_token"`
+	FirstTokenMs      float64 `json:"first_token_ms"`
+	TokensPerSecond   float64 `json:"tokens_per_second"`
+}
+
+// Beam is a result of a beam search, the score is the length normalized
+// log probability.
+type Beam struct {
+	Text   string  `json:"text"`
+	Tokens int     `json:"tokens"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// Completion is the response of a non-stream completion.
+type Completion struct {
+	Prompt         string
+	Text           string
+	Tokens         int
+	Usage          Usage
+	Timing         Timing
+	CompleteReason string
+	// Beams of a beam search, the best first
+	Beams []Beam
+	// ContextShifts is the number of times the context was shifted
+	ContextShifts int
+}
+
+// StreamResponse is one message of a completion stream. Usage, Timing,
+// Beams and Shifts are set in the final message.
+type StreamResponse struct {
+	Text   string  `json:"text"`
+	Finish bool    `json:"finish"`
+	Reason string  `json:"reason"`
+	Error  string  `json:"error,omitempty"`
+	Usage  *Usage  `json:"usage,omitempty"`
+	Timing *Timing `json:"timing,omitempty"`
+	Beams  []Beam  `json:"beams,omitempty"`
+	Shifts int     `json:"context_shifts,omitempty"`
+}
+
+type ModelInfo struct {
+	Name       string
+	Path       string
+	CtxSize    int
+	NumWorkers int
+	Threads    int
+	Swapping   bool
+	SwapError  string
+}
+
+type ModelList struct {
+	Default string
+	Models  []ModelInfo
+}
+
+// APIError is an error response of the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("llama-go: %d %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request can be retried later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// CompletionError is an error the server reported after the completion
+// started.
+type CompletionError struct {
+	Reason  string
+	Message string
+}
+
+func (e *CompletionError) Error() string {
+	return "llama-go: completion failed: " + e.Message
+}
+
+type Client struct {
+	// BaseURL is the address of the server like http://127.0.0.1:4000
+	BaseURL string
+	// APIKey is sent as bearer token if set
+	APIKey     string
+	HTTPClient *http.Client
+	// MaxRetries is the number of retries of requests answered with 429
+	// or 503, the wait starts at RetryWait and doubles on each retry.
+	MaxRetries int
+	RetryWait  time.Duration
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: 3,
+		RetryWait:  time.Second,
+	}
+}
+
+// do sends the request and retries on 429 and 503. Responses other than
+// 200 are returned as *APIError.
+func (c *Client) do(ctx context.Context, method string, path string, body any) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if data != nil {
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.APIKey)
+		}
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		apiErr := readAPIError(resp)
+		if !apiErr.Temporary() || attempt >= c.MaxRetries {
+			return nil, apiErr
+		}
+		delay := wait
+		if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && after > 0 {
+			delay = time.Duration(after) * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		wait *= 2
+	}
+}
+
+func readAPIError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	ret := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+	}
+	body := struct {
+		Error string
+	}{}
+	data, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		ret.Message = body.Error
+	}
+	return ret
+}
+
+func (c *Client) getJSON(ctx context.Context, method string, path string, body any, out any) error {
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Complete generates a completion and returns when it is done.
+func (c *Client) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	req.Stream = false
+	ret := &Completion{}
+	err := c.getJSON(ctx, http.MethodPost, "/api/completion", req, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Tokenize splits the prompt into the tokens of the model, empty model
+// means the default model.
+func (c *Client) Tokenize(ctx context.Context, prompt string, model string) ([]string, error) {
+	query := url.Values{}
+	query.Set("prompt", prompt)
+	if model != "" {
+		query.Set("model", model)
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/api/tokenize?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	stream := newLineStream(ctx, resp.Body)
+	defer stream.Close()
+	tokens := []string{}
+	for stream.Next() {
+		tokens = append(tokens, stream.Response().Text)
+	}
+	return tokens, stream.Err()
+}
+
+// Models lists the models served by the server.
+func (c *Client) Models(ctx context.Context) (*ModelList, error) {
+	ret := &ModelList{}
+	err := c.getJSON(ctx, http.MethodGet, "/api/models", nil, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// IsTemporary reports whether err is an API error that can be retried.
+func IsTemporary(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Temporary()
+}
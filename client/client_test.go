@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// testServer serves /api/completion with handler and returns the request
+// bodies it received.
+func testServer(t *testing.T, handler func(w http.ResponseWriter, body map[string]any)) (*Client, *[]map[string]any) {
+	bodies := []map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/completion" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		body := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		bodies = append(bodies, body)
+		handler(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	c := New(srv.URL)
+	c.APIKey = "key"
+	return c, &bodies
+}
+
+func TestComplete(t *testing.T) {
+	c, _ := testServer(t, func(w http.ResponseWriter, body map[string]any) {
+		if body["stream"] != nil {
+			t.Errorf("stream = %v", body["stream"])
+		}
+		fmt.Fprint(w, `{"Prompt": "Hello", "Text": " world", "Tokens": 1, "CompleteReason": "Limit",
+			"Usage": {"prompt_tokens": 2, "completion_tokens": 1, "total_tokens": 3}}`)
+	})
+
+	res, err := c.Complete(context.Background(), CompletionRequest{Prompt: "Hello", Tokens: 1, Stream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Completion{
+		Prompt:         "Hello",
+		Text:           " world",
+		Tokens:         1,
+		CompleteReason: "Limit",
+		Usage:          Usage{PromptTokens: 2, CompletionTokens: 1, TotalTokens: 3},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("completion = %+v, want %+v", res, want)
+	}
+}
+
+func TestCompleteAPIError(t *testing.T) {
+	c, _ := testServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"Error": "Invalid temp 0, must be positive"}`)
+	})
+
+	_, err := c.Complete(context.Background(), CompletionRequest{Prompt: "Hello", Tokens: 1})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Invalid temp 0, must be positive" {
+		t.Errorf("err = %v", err)
+	}
+	if IsTemporary(err) {
+		t.Error("bad request is temporary")
+	}
+}
+
+func TestCompleteOptionalFields(t *testing.T) {
+	c, bodies := testServer(t, func(w http.ResponseWriter, body map[string]any) {
+		fmt.Fprint(w, `{}`)
+	})
+
+	_, err := c.Complete(context.Background(), CompletionRequest{Prompt: "Hello", Tokens: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Complete(context.Background(), CompletionRequest{
+		Prompt:      "Hello",
+		Tokens:      1,
+		TopK:        Int(1),
+		RepeatLastN: Int(0),
+		MinP:        Float32(0),
+		Instruct:    Bool(false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unset := (*bodies)[0]
+	for _, key := range []string{"top_k", "repeat_lastn", "top_p", "temp", "repeat_penalty", "mirostat", "min_p", "length_penalty", "instruct"} {
+		if v, ok := unset[key]; ok {
+			t.Errorf("nil %s sent as %v", key, v)
+		}
+	}
+	explicit := (*bodies)[1]
+	want := map[string]any{"top_k": 1.0, "repeat_lastn": 0.0, "min_p": 0.0, "instruct": false}
+	for key, v := range want {
+		if got, ok := explicit[key]; !ok || got != v {
+			t.Errorf("%s = %v, want %v", key, got, v)
+		}
+	}
+	if _, ok := explicit["temp"]; ok {
+		t.Error("nil temp sent")
+	}
+}
+
+func TestCompleteStream(t *testing.T) {
+	c, _ := testServer(t, func(w http.ResponseWriter, body map[string]any) {
+		if body["stream"] != true {
+			t.Errorf("stream = %v", body["stream"])
+		}
+		fmt.Fprintln(w, `{"text": " one", "finish": false, "reason": ""}`)
+		fmt.Fprintln(w, `{"text": " two", "finish": false, "reason": ""}`)
+		fmt.Fprintln(w, `{"text": "", "finish": true, "reason": "Limit", "usage": {"prompt_tokens": 1, "completion_tokens": 2, "total_tokens": 3}}`)
+	})
+
+	stream, err := c.CompleteStream(context.Background(), CompletionRequest{Prompt: "Hello", Tokens: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	text := ""
+	var last StreamResponse
+	for stream.Next() {
+		last = stream.Response()
+		text += last.Text
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if text != " one two" || !last.Finish || last.Reason != "Limit" || last.Usage == nil || last.Usage.CompletionTokens != 2 {
+		t.Errorf("text = %q, last = %+v", text, last)
+	}
+}
+
+func TestCompleteStreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines string
+		check func(err error) bool
+	}{
+		{
+			name:  "completion error",
+			lines: `{"text": " one", "finish": false, "reason": ""}` + "\n" + `{"text": "", "finish": true, "reason": "Error", "error": "Worker crashed"}` + "\n",
+			check: func(err error) bool {
+				var compErr *CompletionError
+				return errors.As(err, &compErr) && compErr.Reason == "Error" && compErr.Message == "Worker crashed"
+			},
+		},
+		{
+			name:  "closed early",
+			lines: `{"text": " one", "finish": false, "reason": ""}` + "\n",
+			check: func(err error) bool {
+				return errors.Is(err, io.ErrUnexpectedEOF)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := testServer(t, func(w http.ResponseWriter, body map[string]any) {
+				fmt.Fprint(w, test.lines)
+			})
+			stream, err := c.CompleteStream(context.Background(), CompletionRequest{Prompt: "Hello", Tokens: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			n := 0
+			for stream.Next() {
+				n++
+			}
+			if n != 1 || !test.check(stream.Err()) {
+				t.Errorf("messages = %d, err = %v", n, stream.Err())
+			}
+		})
+	}
+}
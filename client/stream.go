@@ -0,0 +1,157 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Stream iterates over the messages of a completion:
+//
+//	stream, err := c.CompleteStream(ctx, req)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//	for stream.Next() {
+//		fmt.Print(stream.Response().Text)
+//	}
+//	return stream.Err()
+type Stream struct {
+	read  func() (StreamResponse, error)
+	close func() error
+	resp  StreamResponse
+	err   error
+	done  bool
+}
+
+// Next reads the next message, it returns false after the final message
+// or on error.
+func (s *Stream) Next() bool {
+	if s.done {
+		return false
+	}
+	resp, err := s.read()
+	if err != nil {
+		s.err = err
+		s.done = true
+		return false
+	}
+	if resp.Error != "" {
+		s.err = &CompletionError{Reason: resp.Reason, Message: resp.Error}
+		s.done = true
+		return false
+	}
+	s.resp = resp
+	if resp.Finish {
+		s.done = true
+	}
+	return true
+}
+
+// Response returns the message read by Next.
+func (s *Stream) Response() StreamResponse {
+	return s.resp
+}
+
+// Err returns the error that stopped the stream. The stream must end with
+// a final message, so a stream closed early is io.ErrUnexpectedEOF.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+func (s *Stream) Close() error {
+	s.done = true
+	return s.close()
+}
+
+func newLineStream(ctx context.Context, body io.ReadCloser) *Stream {
+	reader := bufio.NewReader(body)
+	return &Stream{
+		read: func() (StreamResponse, error) {
+			resp := StreamResponse{}
+			line, err := reader.ReadBytes('\n')
+			if len(line) == 0 && err != nil {
+				if ctx.Err() != nil {
+					return resp, ctx.Err()
+				}
+				if errors.Is(err, io.EOF) {
+					return resp, io.ErrUnexpectedEOF
+				}
+				return resp, err
+			}
+			err = json.Unmarshal(line, &resp)
+			return resp, err
+		},
+		close: body.Close,
+	}
+}
+
+// CompleteStream generates a completion and returns the generated text as
+// it is produced.
+func (c *Client) CompleteStream(ctx context.Context, req CompletionRequest) (*Stream, error) {
+	req.Stream = true
+	resp, err := c.do(ctx, http.MethodPost, "/api/completion", req)
+	if err != nil {
+		return nil, err
+	}
+	return newLineStream(ctx, resp.Body), nil
+}
+
+// CompleteWebSocket is like CompleteStream but uses the WebSocket API.
+func (c *Client) CompleteWebSocket(ctx context.Context, req CompletionRequest) (*Stream, error) {
+	wsURL := c.BaseURL + "/api/ws/completion"
+	if strings.HasPrefix(wsURL, "http") {
+		wsURL = "ws" + strings.TrimPrefix(wsURL, "http")
+	}
+	header := http.Header{}
+	if c.APIKey != "" {
+		header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return nil, readAPIError(resp)
+		}
+		return nil, err
+	}
+	err = conn.WriteJSON(req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Unblock reads when ctx is done
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	closed := false
+	return &Stream{
+		read: func() (StreamResponse, error) {
+			resp := StreamResponse{}
+			err := conn.ReadJSON(&resp)
+			if err != nil && ctx.Err() != nil {
+				return resp, ctx.Err()
+			}
+			return resp, err
+		},
+		close: func() error {
+			if closed {
+				return nil
+			}
+			closed = true
+			close(stop)
+			return conn.Close()
+		},
+	}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+// readPrompt returns the prompt of the flag, else the content of the file,
+// else stdin. The file "-" also means stdin.
+func readPrompt(prompt string, file string) (string, error) {
+	if prompt != "" {
+		return prompt, nil
+	}
+	if file != "" && file != "-" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read prompt file: %w", err)
+		}
+		return string(data), nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read prompt from stdin: %w", err)
+	}
+	return string(data), nil
+}
+
+// RunComplete generates one completion. The text format streams the
+// generated text to w, the json format writes the same object as the
+// non-stream /api/completion response when the completion is done.
+func RunComplete(model *llama.Model, params *CompletionParams, seed int, format string, w io.Writer) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown output format %q, use text or json", format)
+	}
+	err := validateCompletion(params, nil, Limits{})
+	if err != nil {
+		return err
+	}
+	var (
+		text   strings.Builder
+		buffer strings.Builder
+	)
+	result, err := model.Predict(params.ToPredictParams(seed), params.Prompt, func(word string) {
+		buffer.WriteString(word)
+		if !utf8.ValidString(buffer.String()) {
+			return
+		}
+		if format == "text" {
+			io.WriteString(w, buffer.String())
+		}
+		text.WriteString(buffer.String())
+		buffer.Reset()
+	})
+	if buffer.Len() > 0 {
+		if format == "text" {
+			io.WriteString(w, buffer.String())
+		}
+		text.WriteString(buffer.String())
+	}
+	if err != nil {
+		return err
+	}
+	if format == "text" {
+		return nil
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	ret := map[string]any{
+		"Prompt":         params.Prompt,
+		"Text":           text.String(),
+		"Tokens":         result.Usage.CompletionTokens,
+		"Usage":          result.Usage,
+		"Timing":         result.Timing,
+		"CompleteReason": result.Reason.String(),
+	}
+	if len(result.Beams) > 0 {
+		ret["Beams"] = result.Beams
+	}
+	if result.ContextShifts > 0 {
+		ret["ContextShifts"] = result.ContextShifts
+	}
+	return enc.Encode(ret)
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that is written as "30s" in config files.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+type Config struct {
+	Model       ModelConfig        `yaml:"model" toml:"model"`
+	Pools       []PoolConfig       `yaml:"pools" toml:"pools"`
+	DefaultPool string             `yaml:"default_pool" toml:"default_pool"`
+	Server      ServerConfig       `yaml:"server" toml:"server"`
+	Defaults    CompletionDefaults `yaml:"defaults" toml:"defaults"`
+	Auth        AuthConfig         `yaml:"auth" toml:"auth"`
+	Limits      Limits             `yaml:"limits" toml:"limits"`
+}
+
+type ModelConfig struct {
+	Path    string `yaml:"path" toml:"path"`
+	Backend string `yaml:"backend" toml:"backend"`
+	CtxSize int    `yaml:"ctx_size" toml:"ctx_size"`
+	Threads int    `yaml:"threads" toml:"threads"`
+	Workers int    `yaml:"workers" toml:"workers"`
+	Parts   int    `yaml:"parts" toml:"parts"`
+	Seed    int    `yaml:"seed" toml:"seed"`
+}
+
+// PoolConfig is a named worker pool serving one model. Unset numbers are
+// taken from the model section.
+type PoolConfig struct {
+	Name    string `yaml:"name" toml:"name"`
+	Path    string `yaml:"path" toml:"path"`
+	Backend string `yaml:"backend" toml:"backend"`
+	CtxSize int    `yaml:"ctx_size" toml:"ctx_size"`
+	Threads int    `yaml:"threads" toml:"threads"`
+	Workers int    `yaml:"workers" toml:"workers"`
+	Parts   int    `yaml:"parts" toml:"parts"`
+}
+
+var poolNameRe = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+type ServerConfig struct {
+	Listen     string    `yaml:"listen" toml:"listen"`
+	GRPCListen string    `yaml:"grpc_listen" toml:"grpc_listen"`
+	StaticPath string    `yaml:"static_path" toml:"static_path"`
+	Grace      Duration  `yaml:"grace" toml:"grace"`
+	Debug      bool      `yaml:"debug" toml:"debug"`
+	TLS        TLSConfig `yaml:"tls" toml:"tls"`
+}
+
+type TLSConfig struct {
+	Cert     string `yaml:"cert" toml:"cert"`
+	Key      string `yaml:"key" toml:"key"`
+	ClientCA string `yaml:"client_ca" toml:"client_ca"`
+}
+
+// CompletionDefaults overrides the built-in default sampling parameters of
+// completion requests. Unset fields keep the built-in defaults.
+type CompletionDefaults struct {
+	TopK          *int     `yaml:"top_k" toml:"top_k"`
+	TopP          *float32 `yaml:"top_p" toml:"top_p"`
+	Temp          *float32 `yaml:"temp" toml:"temp"`
+	RepeatPenalty *float32 `yaml:"repeat_penalty" toml:"repeat_penalty"`
+	RepeatLastN   *int     `yaml:"repeat_lastn" toml:"repeat_lastn"`
+	Mirostat      *int     `yaml:"mirostat" toml:"mirostat"`
+	MirostatTau   *float32 `yaml:"mirostat_tau" toml:"mirostat_tau"`
+	MirostatEta   *float32 `yaml:"mirostat_eta" toml:"mirostat_eta"`
+	MinP          *float32 `yaml:"min_p" toml:"min_p"`
+	TypicalP      *float32 `yaml:"typical_p" toml:"typical_p"`
+	TfsZ          *float32 `yaml:"tfs_z" toml:"tfs_z"`
+
+	FrequencyPenalty *float32 `yaml:"frequency_penalty" toml:"frequency_penalty"`
+	PresencePenalty  *float32 `yaml:"presence_penalty" toml:"presence_penalty"`
+	PenaltyExclude   []int    `yaml:"penalty_exclude" toml:"penalty_exclude"`
+
+	// Samplers is the default sampler chain, SamplerPresets are named
+	// chains that requests select by sampler_preset
+	Samplers       []string            `yaml:"samplers" toml:"samplers"`
+	SamplerPresets map[string][]string `yaml:"sampler_presets" toml:"sampler_presets"`
+
+	// Instruct wraps the prompts for Alpaca style models
+	Instruct *bool `yaml:"instruct" toml:"instruct"`
+	// ContextShift keeps generating once the context is full
+	ContextShift *bool `yaml:"context_shift" toml:"context_shift"`
+	ContextKeep  *int  `yaml:"context_keep" toml:"context_keep"`
+}
+
+func (d CompletionDefaults) Apply(p *CompletionParams) {
+	if d.TopK != nil {
+		p.TopK = *d.TopK
+	}
+	if d.TopP != nil {
+		p.TopP = *d.TopP
+	}
+	if d.Temp != nil {
+		p.Temp = *d.Temp
+	}
+	if d.RepeatPenalty != nil {
+		p.RepeatPenalty = *d.RepeatPenalty
+	}
+	if d.RepeatLastN != nil {
+		p.RepeatLastN = *d.RepeatLastN
+	}
+	if d.Mirostat != nil {
+		p.Mirostat = *d.Mirostat
+	}
+	if d.MirostatTau != nil {
+		p.MirostatTau = *d.MirostatTau
+	}
+	if d.MirostatEta != nil {
+		p.MirostatEta = *d.MirostatEta
+	}
+	if d.MinP != nil {
+		p.MinP = *d.MinP
+	}
+	if d.TypicalP != nil {
+		p.TypicalP = *d.TypicalP
+	}
+	if d.TfsZ != nil {
+		p.TfsZ = *d.TfsZ
+	}
+	if d.FrequencyPenalty != nil {
+		p.FrequencyPenalty = *d.FrequencyPenalty
+	}
+	if d.PresencePenalty != nil {
+		p.PresencePenalty = *d.PresencePenalty
+	}
+	if len(d.PenaltyExclude) > 0 {
+		p.PenaltyExclude = d.PenaltyExclude
+	}
+	if len(d.Samplers) > 0 {
+		p.Samplers = d.Samplers
+	}
+	if d.Instruct != nil {
+		p.Instruct = *d.Instruct
+	}
+	if d.ContextShift != nil {
+		p.ContextShift = *d.ContextShift
+	}
+	if d.ContextKeep != nil {
+		p.ContextKeep = *d.ContextKeep
+	}
+}
+
+type AuthConfig struct {
+	APIKeys   []string `yaml:"api_keys" toml:"api_keys"`
+	AdminKeys []string `yaml:"admin_keys" toml:"admin_keys"`
+}
+
+// Limits restricts the size of requests, zero means unlimited.
+type Limits struct {
+	MaxTokens       int `yaml:"max_tokens" toml:"max_tokens"`
+	MaxPromptLength int `yaml:"max_prompt_length" toml:"max_prompt_length"`
+}
+
+func (l Limits) Check(p *CompletionParams) error {
+	if l.MaxTokens > 0 && p.Tokens > l.MaxTokens {
+		return fmt.Errorf("Tokens exceeds limit %d", l.MaxTokens)
+	}
+	if err := l.CheckPrompt(p.NegativePrompt); err != nil {
+		return err
+	}
+	return l.CheckPrompt(p.Prompt)
+}
+
+func (l Limits) CheckPrompt(prompt string) error {
+	if l.MaxPromptLength > 0 && len(prompt) > l.MaxPromptLength {
+		return fmt.Errorf("Prompt length exceeds limit %d", l.MaxPromptLength)
+	}
+	return nil
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Model: ModelConfig{
+			CtxSize: 2048,
+			Threads: 4,
+			Workers: 2,
+			Parts:   -1,
+			Seed:    -1,
+		},
+		Server: ServerConfig{
+			Listen: "127.0.0.1:4000",
+			Grace:  Duration(30 * time.Second),
+		},
+	}
+}
+
+// LoadFile reads a YAML or TOML config file on top of the current values.
+// Unknown keys are rejected.
+func (c *Config) LoadFile(fname string) error {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(fname)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		if errors.Is(err, io.EOF) {
+			// Empty file
+			err = nil
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+		var serr *toml.StrictMissingError
+		if errors.As(err, &serr) {
+			keys := []string{}
+			for _, e := range serr.Errors {
+				row, col := e.Position()
+				keys = append(keys, fmt.Sprintf("%s (line %d, column %d)", strings.Join(e.Key(), "."), row, col))
+			}
+			err = fmt.Errorf("unknown keys %s", strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("Unknown config file format %q, use .yaml, .yml or .toml", filepath.Ext(fname))
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot parse config file %s: %w", fname, err)
+	}
+	return nil
+}
+
+// Validate checks the config and returns all problems found at once.
+func (c *Config) Validate() error {
+	var errs []string
+	addErr := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	if len(c.Pools) == 0 {
+		if c.DefaultPool != "" {
+			addErr("default_pool requires pools")
+		}
+	} else if c.Model.Path != "" {
+		addErr("model.path cannot be used together with pools, set path in each pool")
+	}
+	contextShift := c.Defaults.ContextShift != nil && *c.Defaults.ContextShift
+	names := map[string]bool{}
+	for i, p := range c.PoolConfigs() {
+		prefix := "model"
+		if len(c.Pools) > 0 {
+			prefix = fmt.Sprintf("pools[%d]", i)
+			if !poolNameRe.MatchString(p.Name) {
+				addErr("%s.name must match %s, got %q", prefix, poolNameRe, p.Name)
+			}
+			if names[p.Name] {
+				addErr("%s.name %q is duplicated", prefix, p.Name)
+			}
+			names[p.Name] = true
+		}
+		if p.Path == "" {
+			addErr("%s.path is required", prefix)
+		}
+		if !validBackend(p.Backend) {
+			addErr("%s.backend must be %s or %s, got %q", prefix, LlamaBackendName, FakeBackendName, p.Backend)
+		}
+		if p.CtxSize <= 0 {
+			addErr("%s.ctx_size must be positive, got %d", prefix, p.CtxSize)
+		}
+		if p.Threads <= 0 {
+			addErr("%s.threads must be positive, got %d", prefix, p.Threads)
+		}
+		if p.Workers <= 0 {
+			addErr("%s.workers must be positive, got %d", prefix, p.Workers)
+		}
+		if p.Parts == 0 || p.Parts < -1 {
+			addErr("%s.parts must be -1 (auto) or positive, got %d", prefix, p.Parts)
+		}
+		// with context shifts the generation can outgrow the context
+		if c.Limits.MaxTokens > 0 && c.Limits.MaxTokens > p.CtxSize && !contextShift {
+			addErr("limits.max_tokens %d exceeds %s.ctx_size %d", c.Limits.MaxTokens, prefix, p.CtxSize)
+		}
+	}
+	if c.DefaultPool != "" && len(c.Pools) > 0 && !names[c.DefaultPool] {
+		addErr("default_pool %q is not defined in pools", c.DefaultPool)
+	}
+	if c.Server.Listen == "" {
+		addErr("server.listen is required")
+	}
+	if c.Server.Grace < 0 {
+		addErr("server.grace must not be negative, got %v", time.Duration(c.Server.Grace))
+	}
+	if (c.Server.TLS.Cert == "") != (c.Server.TLS.Key == "") {
+		addErr("server.tls.cert and server.tls.key must be set together")
+	}
+	if c.Server.TLS.ClientCA != "" && c.Server.TLS.Cert == "" {
+		addErr("server.tls.client_ca requires server.tls.cert and server.tls.key")
+	}
+	d := c.Defaults
+	if d.TopK != nil && *d.TopK <= 0 {
+		addErr("defaults.top_k must be positive, got %d", *d.TopK)
+	}
+	if d.TopP != nil && (*d.TopP <= 0 || *d.TopP > 1) {
+		addErr("defaults.top_p must be in (0, 1], got %v", *d.TopP)
+	}
+	if d.Temp != nil && *d.Temp <= 0 {
+		addErr("defaults.temp must be positive, got %v", *d.Temp)
+	}
+	if d.RepeatPenalty != nil && *d.RepeatPenalty <= 0 {
+		addErr("defaults.repeat_penalty must be positive, got %v", *d.RepeatPenalty)
+	}
+	if d.RepeatLastN != nil && *d.RepeatLastN < 0 {
+		addErr("defaults.repeat_lastn must not be negative, got %d", *d.RepeatLastN)
+	}
+	if d.Mirostat != nil && (*d.Mirostat < 0 || *d.Mirostat > 2) {
+		addErr("defaults.mirostat must be 0, 1 or 2, got %d", *d.Mirostat)
+	}
+	if d.MirostatTau != nil && *d.MirostatTau <= 0 {
+		addErr("defaults.mirostat_tau must be positive, got %v", *d.MirostatTau)
+	}
+	if d.MirostatEta != nil && *d.MirostatEta <= 0 {
+		addErr("defaults.mirostat_eta must be positive, got %v", *d.MirostatEta)
+	}
+	if d.MinP != nil && (*d.MinP < 0 || *d.MinP > 1) {
+		addErr("defaults.min_p must be in [0, 1], got %v", *d.MinP)
+	}
+	if d.TypicalP != nil && (*d.TypicalP < 0 || *d.TypicalP > 1) {
+		addErr("defaults.typical_p must be in [0, 1], got %v", *d.TypicalP)
+	}
+	if d.TfsZ != nil && (*d.TfsZ < 0 || *d.TfsZ > 1) {
+		addErr("defaults.tfs_z must be in [0, 1], got %v", *d.TfsZ)
+	}
+	if d.FrequencyPenalty != nil && (*d.FrequencyPenalty < -2 || *d.FrequencyPenalty > 2) {
+		addErr("defaults.frequency_penalty must be in [-2, 2], got %v", *d.FrequencyPenalty)
+	}
+	if d.PresencePenalty != nil && (*d.PresencePenalty < -2 || *d.PresencePenalty > 2) {
+		addErr("defaults.presence_penalty must be in [-2, 2], got %v", *d.PresencePenalty)
+	}
+	for i, id := range d.PenaltyExclude {
+		if id < 0 {
+			addErr("defaults.penalty_exclude[%d] is negative", i)
+		}
+	}
+	if err := checkSamplerChain(d.Samplers); err != nil {
+		addErr("defaults.samplers: %v", err)
+	}
+	if d.ContextKeep != nil && *d.ContextKeep < 0 {
+		addErr("defaults.context_keep must not be negative, got %d", *d.ContextKeep)
+	}
+	for name, chain := range d.SamplerPresets {
+		if name == "" {
+			addErr("defaults.sampler_presets has an empty name")
+		}
+		if len(chain) == 0 {
+			addErr("defaults.sampler_presets.%s is empty", name)
+		}
+		if err := checkSamplerChain(chain); err != nil {
+			addErr("defaults.sampler_presets.%s: %v", name, err)
+		}
+	}
+	for i, key := range c.Auth.APIKeys {
+		if key == "" {
+			addErr("auth.api_keys[%d] is empty", i)
+		}
+	}
+	for i, key := range c.Auth.AdminKeys {
+		if key == "" {
+			addErr("auth.admin_keys[%d] is empty", i)
+		}
+	}
+	if c.Limits.MaxTokens < 0 {
+		addErr("limits.max_tokens must not be negative, got %d", c.Limits.MaxTokens)
+	}
+	if c.Limits.MaxPromptLength < 0 {
+		addErr("limits.max_prompt_length must not be negative, got %d", c.Limits.MaxPromptLength)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid config:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// PoolConfigs returns the worker pools to start. Without pools the model
+// section is served as the default pool.
+func (c *Config) PoolConfigs() []PoolConfig {
+	if len(c.Pools) == 0 {
+		return []PoolConfig{
+			{
+				Name:    DefaultPoolName,
+				Path:    c.Model.Path,
+				Backend: c.Model.Backend,
+				CtxSize: c.Model.CtxSize,
+				Threads: c.Model.Threads,
+				Workers: c.Model.Workers,
+				Parts:   c.Model.Parts,
+			},
+		}
+	}
+	ret := make([]PoolConfig, len(c.Pools))
+	for i, p := range c.Pools {
+		if p.Backend == "" {
+			p.Backend = c.Model.Backend
+		}
+		if p.CtxSize == 0 {
+			p.CtxSize = c.Model.CtxSize
+		}
+		if p.Threads == 0 {
+			p.Threads = c.Model.Threads
+		}
+		if p.Workers == 0 {
+			p.Workers = c.Model.Workers
+		}
+		if p.Parts == 0 {
+			p.Parts = c.Model.Parts
+		}
+		ret[i] = p
+	}
+	return ret
+}
+
+// DefaultPoolName returns the pool of requests without a model.
+func (c *Config) DefaultPoolName() string {
+	if c.DefaultPool != "" {
+		return c.DefaultPool
+	}
+	if len(c.Pools) > 0 {
+		return c.Pools[0].Name
+	}
+	return DefaultPoolName
+}
+
+func (c *Config) TLSOptions() TLSOptions {
+	return TLSOptions{
+		CertFile:     c.Server.TLS.Cert,
+		KeyFile:      c.Server.TLS.Key,
+		ClientCAFile: c.Server.TLS.ClientCA,
+	}
+}
@@ -0,0 +1,664 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cornelk/llama-go/client"
+	"github.com/cornelk/llama-go/llama"
+	"github.com/cornelk/llama-go/llamapb"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// The test binary doubles as the worker executable: the master starts it
+// again with this variable set and the worker flags.
+const testMainEnv = "LLAMA_GO_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const e2eScript = `
+tokens: [" a", " b"]
+delay: 2ms
+rules:
+  - prompt: "echo one"
+    tokens: [" one"]
+  - prompt: "echo two"
+    tokens: [" two"]
+  - prompt: "slow"
+    delay: 50ms
+  - prompt: "fail"
+    fail_after: 1
+    error: "scripted failure"
+  - prompt: "crash"
+    crash_after: 2
+`
+
+// startPool starts a pool of fake worker processes running the script.
+func startPool(t *testing.T, script string, workers int) *WorkerManager {
+	t.Helper()
+	t.Setenv(testMainEnv, "1")
+	scriptFile := filepath.Join(t.TempDir(), "fake.yaml")
+	err := os.WriteFile(scriptFile, []byte(script), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := fmt.Sprintf("e2e-%d-%s", os.Getpid(), strings.ReplaceAll(t.Name(), "/", "-"))
+	wm := NewWorkerManager(name, exe, scriptFile, FakeBackendName, workers, 64, 1, 1, false)
+	wm.StartWorkers()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		wm.Shutdown(ctx)
+	})
+	err = wm.WaitReady()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wm
+}
+
+// startServer serves the HTTP API of the pool.
+func startServer(t *testing.T, wm *WorkerManager) *client.Client {
+	t.Helper()
+	_, c := startAPIServer(t, wm)
+	return c
+}
+
+func startAPIServer(t *testing.T, wm *WorkerManager) (*APIServer, *client.Client) {
+	t.Helper()
+	pools := NewWorkerPools(DefaultPoolName)
+	pools.Add(DefaultPoolName, wm)
+	s := &APIServer{
+		Pools:      pools,
+		StaticPath: t.TempDir(),
+	}
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	s.setupRouter(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	c := client.New(srv.URL)
+	c.MaxRetries = 0
+	return s, c
+}
+
+// startGRPCServer serves the gRPC API of the pool on a unix socket.
+func startGRPCServer(t *testing.T, wm *WorkerManager) llamapb.LlamaClient {
+	t.Helper()
+	pools := NewWorkerPools(DefaultPoolName)
+	pools.Add(DefaultPoolName, wm)
+	sockFile := filepath.Join(t.TempDir(), "grpc.sock")
+	s := &GRPCServer{
+		Pools:  pools,
+		Listen: "unix:" + sockFile,
+	}
+	go s.Run()
+	conn, err := grpc.Dial("unix:"+sockFile, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+	return llamapb.NewLlamaClient(conn)
+}
+
+func runJob(wm *WorkerManager, job string, prompt string, tokens int) (string, *Job) {
+	j := NewJob(job, prompt, llama.PredictParams{Tokens: tokens})
+	wm.DispatchJob(j)
+	var text strings.Builder
+	for words := range j.Response {
+		text.WriteString(strings.Join(words, ""))
+	}
+	return text.String(), j
+}
+
+func TestE2EDispatch(t *testing.T) {
+	wm := startPool(t, e2eScript, 2)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			prompt, want := "echo one", " one one one"
+			if i%2 == 1 {
+				prompt, want = "echo two", " two two two"
+			}
+			text, job := runJob(wm, CompletionJob, prompt, 3)
+			if job.Err != nil || text != want || job.Reason != "Stop" {
+				errs <- fmt.Errorf("job %d: got %q %q %v, want %q", i, text, job.Reason, job.Err, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	text, job := runJob(wm, TokenizeJob, "Hello big world", 0)
+	if job.Err != nil || text != "Hello big world" {
+		t.Errorf("tokenize: got %q %v", text, job.Err)
+	}
+	_, job = runJob(wm, EmbedJob, "Hello", 0)
+	if job.Err != nil || len(job.Embedding) != 8 {
+		t.Errorf("embed: got %v %v", job.Embedding, job.Err)
+	}
+	_, job = runJob(wm, CompletionJob, "please fail", 5)
+	if job.Err == nil || job.Err.Error() != "scripted failure" || job.Reason != "Error" {
+		t.Errorf("fail: got %q %v", job.Reason, job.Err)
+	}
+}
+
+func TestE2EStream(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	c := startServer(t, wm)
+	ctx := context.Background()
+
+	stream, err := c.CompleteStream(ctx, client.CompletionRequest{Prompt: "Hello", Tokens: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	words := []string{}
+	var last client.StreamResponse
+	for stream.Next() {
+		last = stream.Response()
+		if !last.Finish {
+			words = append(words, last.Text)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(words, "|") != " a| b| a| b| a" {
+		t.Errorf("words = %q", words)
+	}
+	if last.Reason != "Stop" || last.Usage == nil || last.Usage.CompletionTokens != 5 || last.Usage.PromptTokens != 2 {
+		t.Errorf("final = %+v", last)
+	}
+
+	// A failing job ends the stream with an error frame
+	stream, err = c.CompleteStream(ctx, client.CompletionRequest{Prompt: "please fail", Tokens: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	for stream.Next() {
+	}
+	var cerr *client.CompletionError
+	if !errors.As(stream.Err(), &cerr) || cerr.Message != "scripted failure" {
+		t.Errorf("stream err = %v, want scripted failure", stream.Err())
+	}
+
+	res, err := c.Complete(ctx, client.CompletionRequest{Prompt: "echo two", Tokens: 2})
+	if err != nil || res.Text != " two two" || res.Tokens != 2 {
+		t.Errorf("complete = %+v %v", res, err)
+	}
+	tokens, err := c.Tokenize(ctx, "Hello world", "")
+	if err != nil || strings.Join(tokens, "|") != "|Hello| world" {
+		t.Errorf("tokenize = %q %v", tokens, err)
+	}
+}
+
+func TestE2EWorkerCrash(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+
+	text, job := runJob(wm, CompletionJob, "please crash", 10)
+	if job.Err == nil || job.Reason != "Error" {
+		t.Fatalf("crash: got %q %q %v, want error", text, job.Reason, job.Err)
+	}
+	if !errors.Is(job.Err, io.EOF) {
+		t.Errorf("crash: err = %v, want EOF", job.Err)
+	}
+	// Words written just before the crash can be lost
+	if !strings.HasPrefix(" a b", text) {
+		t.Errorf("crash: text before crash = %q", text)
+	}
+
+	// The worker is restarted and the client connects again
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		text, job = runJob(wm, CompletionJob, "echo one", 2)
+	}()
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		t.Fatal("job after crash did not finish")
+	}
+	if job.Err != nil || text != " one one" {
+		t.Errorf("after crash: got %q %v", text, job.Err)
+	}
+}
+
+func TestE2EConcurrentDispatch(t *testing.T) {
+	wm := startPool(t, e2eScript, 2)
+
+	// A crashed worker process is restarted after restartWait while jobs
+	// are dispatched and finished on the other one
+	_, job := runJob(wm, CompletionJob, "please crash", 10)
+	if job.Err == nil {
+		t.Fatal("crash: want error")
+	}
+	deadline := time.Now().Add(2 * restartWait)
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				text, job := runJob(wm, CompletionJob, "echo one", 1)
+				if job.Err != nil || text != " one" {
+					errs <- fmt.Errorf("dispatcher %d: got %q %v", i, text, job.Err)
+					return
+				}
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		t.Fatal("jobs did not finish")
+	}
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestE2EReconnect(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	if _, job := runJob(wm, CompletionJob, "Hello", 2); job.Err != nil {
+		t.Fatal(job.Err)
+	}
+
+	// Kill the idle worker, the client still holds the old connection
+	wm.lock.Lock()
+	worker := wm.workers[0]
+	worker.cmd.Process.Signal(syscall.SIGKILL)
+	wm.lock.Unlock()
+	time.Sleep(restartWait)
+	err := wm.WaitReady()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		started := worker.start.Load()
+		conn, err := net.Dial("unix", worker.sockFile)
+		if err == nil && started {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("worker was not restarted")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	text, job := runJob(wm, CompletionJob, "echo two", 2)
+	if job.Err != nil || text != " two two" {
+		t.Errorf("after restart: got %q %v", text, job.Err)
+	}
+}
+
+// errConn fails every read with err.
+type errConn struct {
+	net.Conn
+	err error
+}
+
+func (c *errConn) Read([]byte) (int, error) {
+	return 0, c.err
+}
+
+func (c *errConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (c *errConn) Close() error {
+	return nil
+}
+
+func TestProcessJobReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	c := &workerClient{conn: &errConn{err: readErr}, stop: make(chan struct{})}
+	job := NewJob(CompletionJob, "x", llama.PredictParams{Tokens: 1})
+	c.processJob(job)
+	for range job.Response {
+	}
+	if !errors.Is(job.Err, readErr) || job.Reason != "Error" {
+		t.Errorf("job = %q %v, want the read error", job.Reason, job.Err)
+	}
+	if c.conn != nil {
+		t.Error("broken connection was kept")
+	}
+}
+
+// startRawWorker answers a request with the given lines and closes the
+// connection.
+func startRawWorker(t *testing.T, lines ...string) string {
+	t.Helper()
+	sockFile := filepath.Join(t.TempDir(), "raw.sock")
+	lis, err := net.Listen("unix", sockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		lis.Close()
+	})
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				if _, err := reader.ReadBytes('\n'); err != nil {
+					return
+				}
+				for _, line := range lines {
+					conn.Write([]byte(line))
+				}
+			}()
+		}
+	}()
+	return sockFile
+}
+
+func TestProcessJobMalformedResponse(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"garbage", []string{"not json\n"}, "Invalid worker response"},
+		{"partial line", []string{`{"Text":["a"],"Finish":false}` + "\n", `{"Text":[`}, "Read worker response: EOF"},
+		{"closed", nil, "Read worker response: EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sockFile := startRawWorker(t, tt.lines...)
+			c := &workerClient{sockFile: sockFile, stop: make(chan struct{})}
+			defer c.Close()
+			job := NewJob(CompletionJob, "x", llama.PredictParams{Tokens: 1})
+			c.processJob(job)
+			for range job.Response {
+			}
+			if job.Err == nil || !strings.HasPrefix(job.Err.Error(), tt.want) {
+				t.Errorf("err = %v, want %s", job.Err, tt.want)
+			}
+			if c.conn != nil {
+				t.Error("broken connection was kept")
+			}
+		})
+	}
+}
+
+func TestE2EConcurrentWebSocket(t *testing.T) {
+	wm := startPool(t, e2eScript, 2)
+	c := startServer(t, wm)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			prompt, want := "echo one", " one one one one"
+			if i%2 == 1 {
+				prompt, want = "echo two", " two two two two"
+			}
+			// Each connection runs two completions one after another
+			for round := 0; round < 2; round++ {
+				stream, err := c.CompleteWebSocket(context.Background(), client.CompletionRequest{Prompt: prompt, Tokens: 4})
+				if err != nil {
+					errs <- err
+					return
+				}
+				var text strings.Builder
+				for stream.Next() {
+					text.WriteString(stream.Response().Text)
+				}
+				stream.Close()
+				if stream.Err() != nil || text.String() != want {
+					errs <- fmt.Errorf("client %d: got %q %v, want %q", i, text.String(), stream.Err(), want)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestE2EWebSocketShutdown(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	s, c := startAPIServer(t, wm)
+
+	idle, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(c.BaseURL, "http")+"/api/ws/completion", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	stream, err := c.CompleteWebSocket(context.Background(), client.CompletionRequest{Prompt: "slow", Tokens: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if !stream.Next() {
+		t.Fatal(stream.Err())
+	}
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		shutdown <- s.Shutdown(ctx)
+	}()
+	// The running completion finishes before the connection is closed
+	n := 1
+	for stream.Next() {
+		n++
+	}
+	if stream.Err() != nil || n != 6 {
+		t.Errorf("got %d messages, err = %v, want 5 words and the final one", n, stream.Err())
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if _, _, err := idle.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("idle connection: err = %v, want going away", err)
+	}
+}
+
+func TestE2EGRPCCancel(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	c := startGRPCServer(t, wm)
+
+	// The slow completion would take 5 seconds
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.Complete(ctx, &llamapb.CompleteRequest{Prompt: "slow", Tokens: 100}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("err = %v, want canceled", err)
+	}
+
+	// The single worker is free again once the job is canceled
+	done := make(chan *Job)
+	go func() {
+		_, job := runJob(wm, CompletionJob, "echo one", 2)
+		done <- job
+	}()
+	select {
+	case job := <-done:
+		if job.Err != nil {
+			t.Error(job.Err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("canceled job is still running")
+	}
+}
+
+func TestE2EStreamDisconnect(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	c := startServer(t, wm)
+
+	// More chunks than the job buffers, the handlers drain the rest after
+	// the client went away so the single worker stays usable
+	req := client.CompletionRequest{Prompt: "Hello", Tokens: 400}
+	stream, err := c.CompleteStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Next()
+	stream.Close()
+	ws, err := c.CompleteWebSocket(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Next()
+	ws.Close()
+
+	done := make(chan string)
+	go func() {
+		text, _ := runJob(wm, CompletionJob, "echo one", 2)
+		done <- text
+	}()
+	select {
+	case text := <-done:
+		if text != " one one" {
+			t.Errorf("text = %q", text)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("worker is blocked by the disconnected streams")
+	}
+}
+
+func TestE2EBatchContextShift(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+
+	// the fake context of 64 tokens is shifted twice in 100 tokens
+	run := WorkerBatchRun(wm, 1)
+	res, err := run(context.Background(), &CompletionParams{Prompt: "echo one", Tokens: 100, ContextShift: true})
+	if err != nil || res.ContextShifts != 2 || res.Usage.CompletionTokens != 100 {
+		t.Errorf("result = %+v, err = %v", res, err)
+	}
+}
+
+func TestE2ENegativePromptTooLong(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	c := startServer(t, wm)
+
+	// 70 tokens do not fit into the context of 64 tokens
+	req := client.CompletionRequest{
+		Prompt:         "Hello",
+		Tokens:         2,
+		NegativePrompt: strings.Repeat(" no", 70),
+		CFGScale:       2,
+	}
+	_, err := c.Complete(context.Background(), req)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !strings.HasPrefix(apiErr.Message, llama.ErrPromptTooLong.Error()) {
+		t.Errorf("err = %v, want prompt too long", err)
+	}
+
+	_, job := runJob(wm, CompletionJob, "echo one", 2)
+	if job.Err != nil {
+		t.Errorf("next job err = %v", job.Err)
+	}
+}
+
+func TestE2EBeamPromptTooLong(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	c := startServer(t, wm)
+
+	req := client.CompletionRequest{Prompt: strings.Repeat(" long", 70), Tokens: 2, NumBeams: 2}
+	_, err := c.Complete(context.Background(), req)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !strings.HasPrefix(apiErr.Message, llama.ErrPromptTooLong.Error()) {
+		t.Errorf("err = %v, want prompt too long", err)
+	}
+}
+
+func TestE2EUnavailable(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	c := startServer(t, wm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wm.Shutdown(ctx)
+	_, err := c.Complete(context.Background(), client.CompletionRequest{Prompt: "Hello", Tokens: 2})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("err = %v, want 503", err)
+	}
+}
+
+func TestE2ELoadFailure(t *testing.T) {
+	t.Setenv(testMainEnv, "1")
+	scriptFile := filepath.Join(t.TempDir(), "fake.yaml")
+	os.WriteFile(scriptFile, []byte("load_error: broken model\n"), 0644)
+	exe, _ := os.Executable()
+	wm := NewWorkerManager(fmt.Sprintf("e2e-%d-load", os.Getpid()), exe, scriptFile, FakeBackendName, 1, 64, 1, 1, false)
+	wm.StartWorkers()
+	defer wm.Shutdown(context.Background())
+	if err := wm.WaitReady(); err == nil {
+		t.Fatal("want error for a worker that cannot load")
+	}
+	_, job := runJob(wm, CompletionJob, "Hello", 2)
+	if !errors.Is(job.Err, ErrNoWorker) {
+		t.Errorf("err = %v, want %v", job.Err, ErrNoWorker)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cornelk/llama-go/llama"
+	"gopkg.in/yaml.v3"
+)
+
+// FakeBehavior is what the fake backend does for a completion.
+type FakeBehavior struct {
+	// Tokens are generated in a loop until the token limit, with EOS set
+	// only once.
+	Tokens []string `yaml:"tokens"`
+	EOS    bool     `yaml:"eos"`
+	// Delay is the time per generated token
+	Delay Duration `yaml:"delay"`
+	// FailAfter fails the completion with Error after so many tokens
+	FailAfter *int `yaml:"fail_after"`
+	// CrashAfter exits the worker process after so many tokens
+	CrashAfter *int   `yaml:"crash_after"`
+	Error      string `yaml:"error"`
+}
+
+type FakeRule struct {
+	// Prompt selects the rule for prompts that contain it
+	Prompt       string `yaml:"prompt"`
+	FakeBehavior `yaml:",inline"`
+}
+
+// FakeScript configures the fake backend. It is read from the model path
+// as YAML, so worker processes started by the master get it by -m.
+type FakeScript struct {
+	FakeBehavior  `yaml:",inline"`
+	LoadDelay     Duration `yaml:"load_delay"`
+	LoadError     string   `yaml:"load_error"`
+	EmbeddingSize int      `yaml:"embedding_size"`
+	Perplexity    float64  `yaml:"perplexity"`
+	// Rules override the behavior for matching prompts, the first match
+	// wins and its unset fields are taken from the script.
+	Rules []FakeRule `yaml:"rules"`
+}
+
+// FakeBackend is a deterministic backend for tests that does not need a
+// model file.
+type FakeBackend struct {
+	Script FakeScript
+
+	path    string
+	nctx    int
+	threads int
+	exit    func(code int)
+}
+
+// NewFakeBackend creates a fake backend reading its script from path on
+// Load, empty path keeps the default script.
+func NewFakeBackend(path string, nctx int, threads int) *FakeBackend {
+	return &FakeBackend{
+		path:    path,
+		nctx:    nctx,
+		threads: threads,
+		exit:    os.Exit,
+	}
+}
+
+func (b *FakeBackend) Load() error {
+	if b.path != "" {
+		data, err := os.ReadFile(b.path)
+		if err != nil {
+			return err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&b.Script)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("Cannot parse fake script %s: %w", b.path, err)
+		}
+	}
+	time.Sleep(time.Duration(b.Script.LoadDelay))
+	if b.Script.LoadError != "" {
+		return errors.New(b.Script.LoadError)
+	}
+	return nil
+}
+
+func (b *FakeBackend) behavior(prompt string) FakeBehavior {
+	ret := b.Script.FakeBehavior
+	for _, rule := range b.Script.Rules {
+		if !strings.Contains(prompt, rule.Prompt) {
+			continue
+		}
+		if len(rule.Tokens) > 0 {
+			ret.Tokens = rule.Tokens
+		}
+		if rule.EOS {
+			ret.EOS = true
+		}
+		if rule.Delay > 0 {
+			ret.Delay = rule.Delay
+		}
+		if rule.FailAfter != nil {
+			ret.FailAfter = rule.FailAfter
+		}
+		if rule.CrashAfter != nil {
+			ret.CrashAfter = rule.CrashAfter
+		}
+		if rule.Error != "" {
+			ret.Error = rule.Error
+		}
+		break
+	}
+	if len(ret.Tokens) == 0 {
+		ret.Tokens = []string{" fake"}
+	}
+	if ret.Error == "" {
+		ret.Error = "Fake failure"
+	}
+	return ret
+}
+
+func (b *FakeBackend) Predict(ctx context.Context, params llama.PredictParams, text string, cb llama.WordCallbackFn) (llama.PredictResult, error) {
+	bh := b.behavior(text)
+	prompt, _ := b.Tokenize(text)
+	ret := llama.PredictResult{
+		Reason: llama.PROMPT_STOP,
+	}
+	if params.NumBeams > 1 && b.nctx > 0 && len(prompt) >= b.nctx {
+		ret.Reason = llama.PROMPT_ERR
+		return ret, fmt.Errorf("%w, %d tokens do not fit into the context size %d", llama.ErrPromptTooLong, len(prompt), b.nctx)
+	}
+	if params.CFGScale > 1 && b.nctx > 0 {
+		negative, _ := b.Tokenize(params.NegativePrompt)
+		if len(negative) >= b.nctx {
+			ret.Reason = llama.PROMPT_ERR
+			return ret, fmt.Errorf("%w, the negative prompt does not fit into the context size %d", llama.ErrPromptTooLong, b.nctx)
+		}
+	}
+	start := time.Now()
+	var generated strings.Builder
+	// tokens in the context, shifted like in the llama backend
+	past := len(prompt)
+	n := 0
+	for ; n < params.Tokens; n++ {
+		if bh.CrashAfter != nil && n >= *bh.CrashAfter {
+			b.exit(3)
+		}
+		if bh.FailAfter != nil && n >= *bh.FailAfter {
+			ret.Reason = llama.PROMPT_ERR
+			ret.Usage = fakeUsage(len(prompt), n)
+			return ret, errors.New(bh.Error)
+		}
+		if bh.EOS && n >= len(bh.Tokens) {
+			ret.Reason = llama.PROMPT_FINISH
+			break
+		}
+		if bh.Delay > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Duration(bh.Delay)):
+			}
+		}
+		if ctx.Err() != nil {
+			ret.Reason = llama.PROMPT_CANCEL
+			break
+		}
+		if n == 0 {
+			ret.Timing.FirstTokenMs = float64(time.Since(start).Microseconds()) / 1000
+		}
+		if params.ContextShift && b.nctx > 0 && past >= b.nctx {
+			keep := params.ContextKeep
+			if keep == 0 || keep > len(prompt) {
+				keep = len(prompt)
+			}
+			if keep > b.nctx/2 {
+				keep = b.nctx / 2
+			}
+			past = keep + (past-keep)/2
+			ret.ContextShifts++
+		}
+		past++
+		generated.WriteString(bh.Tokens[n%len(bh.Tokens)])
+		cb(bh.Tokens[n%len(bh.Tokens)])
+		if ctx.Err() != nil {
+			n++
+			ret.Reason = llama.PROMPT_CANCEL
+			break
+		}
+	}
+	ret.Usage = fakeUsage(len(prompt), n)
+	if elapsed := time.Since(start); n > 0 && elapsed > 0 {
+		ret.Timing.TokensPerSecond = float64(n) / elapsed.Seconds()
+	}
+	if params.NumBeams > 1 {
+		// every beam has the generated text with a lower score
+		for i := 0; i < params.NumReturnBeams || i == 0; i++ {
+			ret.Beams = append(ret.Beams, llama.Beam{
+				Text:   generated.String(),
+				Tokens: n,
+				Score:  -float64(i),
+				Reason: ret.Reason.String(),
+			})
+		}
+	}
+	return ret, nil
+}
+
+func fakeUsage(prompt int, completion int) llama.Usage {
+	return llama.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
+
+// Tokenize splits the prompt before each space, the first token is the
+// empty begin of text token like in the llama backend.
+func (b *FakeBackend) Tokenize(prompt string) ([]string, error) {
+	ret := []string{""}
+	start := 0
+	for i := 1; i < len(prompt); i++ {
+		if prompt[i] == ' ' && prompt[i-1] != ' ' {
+			ret = append(ret, prompt[start:i])
+			start = i
+		}
+	}
+	if start < len(prompt) {
+		ret = append(ret, prompt[start:])
+	}
+	return ret, nil
+}
+
+// Embed returns values derived from the hash of the text.
+func (b *FakeBackend) Embed(text string) ([]float32, error) {
+	size := b.Script.EmbeddingSize
+	if size <= 0 {
+		size = 8
+	}
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	ret := make([]float32, size)
+	for i := range ret {
+		h.Write([]byte{byte(i)})
+		ret[i] = float32(h.Sum64()%2000)/1000 - 1
+	}
+	return ret, nil
+}
+
+func (b *FakeBackend) Perplexity(text string, cb llama.PerplexityCallbackFn) (float64, error) {
+	tokens, _ := b.Tokenize(text)
+	chunks := len(tokens) / b.nctx
+	if chunks == 0 {
+		return 0, fmt.Errorf("Text is too short, need at least %d tokens", b.nctx)
+	}
+	ppl := b.Script.Perplexity
+	if ppl == 0 {
+		ppl = 1
+	}
+	for i := 1; i <= chunks; i++ {
+		cb(llama.PerplexityChunk{Chunk: i, Chunks: chunks, Perplexity: ppl})
+	}
+	return ppl, nil
+}
+
+func (b *FakeBackend) Info() BackendInfo {
+	return BackendInfo{
+		Backend: FakeBackendName,
+		Path:    b.path,
+		CtxSize: b.nctx,
+		Threads: b.threads,
+	}
+}
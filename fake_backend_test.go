@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func fakePredict(t *testing.T, b *FakeBackend, ctx context.Context, prompt string, tokens int) ([]string, llama.PredictResult, error) {
+	t.Helper()
+	words := []string{}
+	res, err := b.Predict(ctx, llama.PredictParams{Tokens: tokens}, prompt, func(word string) {
+		words = append(words, word)
+	})
+	return words, res, err
+}
+
+func TestFakeBackendPredict(t *testing.T) {
+	b := NewFakeBackend("", 64, 1)
+	b.Script.Tokens = []string{"a", "b", "c"}
+
+	words, res, err := fakePredict(t, b, context.Background(), "Hello world", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "a", "b"}; !reflect.DeepEqual(words, want) {
+		t.Errorf("words = %q, want %q", words, want)
+	}
+	if res.Reason != llama.PROMPT_STOP {
+		t.Errorf("reason = %v, want Stop", res.Reason)
+	}
+	want := llama.Usage{PromptTokens: 3, CompletionTokens: 5, TotalTokens: 8}
+	if res.Usage != want {
+		t.Errorf("usage = %+v, want %+v", res.Usage, want)
+	}
+}
+
+func TestFakeBackendEOS(t *testing.T) {
+	b := NewFakeBackend("", 64, 1)
+	b.Script.Tokens = []string{"a", "b"}
+	b.Script.EOS = true
+
+	words, res, err := fakePredict(t, b, context.Background(), "x", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != 2 || res.Reason != llama.PROMPT_FINISH {
+		t.Errorf("got %q %v, want 2 words and Finish", words, res.Reason)
+	}
+}
+
+func TestFakeBackendFailAfter(t *testing.T) {
+	b := NewFakeBackend("", 64, 1)
+	b.Script.FailAfter = intPtr(2)
+	b.Script.Error = "boom"
+
+	words, res, err := fakePredict(t, b, context.Background(), "x", 10)
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	if len(words) != 2 || res.Reason != llama.PROMPT_ERR || res.Usage.CompletionTokens != 2 {
+		t.Errorf("got %q %v %+v", words, res.Reason, res.Usage)
+	}
+}
+
+func TestFakeBackendCrashAfter(t *testing.T) {
+	b := NewFakeBackend("", 64, 1)
+	b.Script.CrashAfter = intPtr(1)
+	crashed := 0
+	b.exit = func(code int) {
+		crashed = code
+		panic("exit")
+	}
+
+	words := []string{}
+	func() {
+		defer func() {
+			recover()
+		}()
+		b.Predict(context.Background(), llama.PredictParams{Tokens: 10}, "x", func(word string) {
+			words = append(words, word)
+		})
+	}()
+	if crashed == 0 || len(words) != 1 {
+		t.Errorf("crashed = %d after %d words, want exit after 1 word", crashed, len(words))
+	}
+}
+
+func TestFakeBackendCancel(t *testing.T) {
+	b := NewFakeBackend("", 64, 1)
+	b.Script.Delay = Duration(time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	res, err := b.Predict(ctx, llama.PredictParams{Tokens: 100}, "x", func(string) {
+		n++
+		if n == 3 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Reason != llama.PROMPT_CANCEL || res.Usage.CompletionTokens != 3 {
+		t.Errorf("got %v after %d tokens, want Cancel after 3", res.Reason, res.Usage.CompletionTokens)
+	}
+}
+
+func TestFakeBackendRules(t *testing.T) {
+	b := NewFakeBackend("", 64, 1)
+	b.Script.Tokens = []string{"default"}
+	b.Script.Rules = []FakeRule{
+		{Prompt: "fail", FakeBehavior: FakeBehavior{FailAfter: intPtr(0)}},
+		{Prompt: "hi", FakeBehavior: FakeBehavior{Tokens: []string{"hello"}}},
+	}
+
+	words, _, err := fakePredict(t, b, context.Background(), "say hi", 1)
+	if err != nil || !reflect.DeepEqual(words, []string{"hello"}) {
+		t.Errorf("hi rule: got %q %v", words, err)
+	}
+	_, _, err = fakePredict(t, b, context.Background(), "please fail", 1)
+	if err == nil || err.Error() != "Fake failure" {
+		t.Errorf("fail rule: err = %v, want Fake failure", err)
+	}
+	words, _, _ = fakePredict(t, b, context.Background(), "other", 1)
+	if !reflect.DeepEqual(words, []string{"default"}) {
+		t.Errorf("no rule: got %q", words)
+	}
+}
+
+func TestFakeBackendLoad(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "fake.yaml")
+	script := `
+tokens: [" one", " two"]
+delay: 1ms
+rules:
+  - prompt: crash
+    crash_after: 3
+`
+	if err := os.WriteFile(fname, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := NewFakeBackend(fname, 64, 1)
+	if err := b.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Script.Tokens) != 2 || b.Script.Delay != Duration(time.Millisecond) {
+		t.Errorf("script = %+v", b.Script)
+	}
+	if len(b.Script.Rules) != 1 || *b.Script.Rules[0].CrashAfter != 3 {
+		t.Errorf("rules = %+v", b.Script.Rules)
+	}
+
+	if err := os.WriteFile(fname, []byte("tokenz: [x]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewFakeBackend(fname, 64, 1).Load(); err == nil {
+		t.Error("unknown key: want error")
+	}
+	if err := os.WriteFile(fname, []byte("load_error: no model\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewFakeBackend(fname, 64, 1).Load(); err == nil || err.Error() != "no model" {
+		t.Errorf("load_error: err = %v", err)
+	}
+}
+
+func TestFakeBackendTokenize(t *testing.T) {
+	b := NewFakeBackend("", 64, 1)
+	tests := map[string][]string{
+		"":             {""},
+		"Hello":        {"", "Hello"},
+		"Hello world":  {"", "Hello", " world"},
+		" a  b":        {"", " a", "  b"},
+		"trailing sp ": {"", "trailing", " sp", " "},
+	}
+	for prompt, want := range tests {
+		got, err := b.Tokenize(prompt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", prompt, got, want)
+		}
+		if strings.Join(got, "") != prompt {
+			t.Errorf("Tokenize(%q) does not join to the prompt", prompt)
+		}
+	}
+}
+
+func TestFakeBackendEmbedPerplexity(t *testing.T) {
+	b := NewFakeBackend("", 3, 1)
+	e1, _ := b.Embed("a")
+	e2, _ := b.Embed("a")
+	e3, _ := b.Embed("b")
+	if len(e1) != 8 || !reflect.DeepEqual(e1, e2) || reflect.DeepEqual(e1, e3) {
+		t.Errorf("embeddings not deterministic per text: %v %v %v", e1, e2, e3)
+	}
+
+	if _, err := b.Perplexity("a", func(llama.PerplexityChunk) {}); err == nil {
+		t.Error("short text: want error")
+	}
+	chunks := []llama.PerplexityChunk{}
+	ppl, err := b.Perplexity("a b c d e", func(c llama.PerplexityChunk) {
+		chunks = append(chunks, c)
+	})
+	if err != nil || ppl != 1 || len(chunks) != 2 || chunks[1].Chunks != 2 {
+		t.Errorf("got %v %v %+v", ppl, err, chunks)
+	}
+}
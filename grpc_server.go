@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+
+	"github.com/cornelk/llama-go/llama"
+	"github.com/cornelk/llama-go/llamapb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+type GRPCServer struct {
+	llamapb.UnimplementedLlamaServer
+	Seed     int
+	Pools    *WorkerPools
+	Listen   string
+	TLS      *tls.Config
+	Defaults CompletionDefaults
+	Limits   Limits
+	APIKeys  APIKeys
+	srv      *grpc.Server
+}
+
+func (s *GRPCServer) Run() error {
+	lis, err := listen(s.Listen)
+	if err != nil {
+		return err
+	}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.APIKeys.UnaryInterceptor()),
+		grpc.StreamInterceptor(s.APIKeys.StreamInterceptor()),
+	}
+	if s.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.TLS)))
+		log.Println("[gRPC Server] Starting with TLS at", s.Listen)
+	} else {
+		log.Println("[gRPC Server] Starting at", s.Listen)
+	}
+	s.srv = grpc.NewServer(opts...)
+	llamapb.RegisterLlamaServer(s.srv, s)
+	return s.srv.Serve(lis)
+}
+
+// Shutdown stops accepting new RPCs and waits for running RPCs until ctx
+// is done, after that the remaining RPCs are cancelled.
+func (s *GRPCServer) Shutdown(ctx context.Context) {
+	if s.srv == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.srv.Stop()
+	}
+}
+
+// jobStatusErr returns Unavailable if the job could not run because of the
+// server state, so that clients can retry.
+func jobStatusErr(err error) error {
+	if errors.Is(err, ErrShuttingDown) || errors.Is(err, ErrNoWorker) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if errors.Is(err, llama.ErrPromptTooLong) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *GRPCServer) Complete(req *llamapb.CompleteRequest, stream llamapb.Llama_CompleteServer) error {
+	reqParams := &CompletionParams{
+		Model:         req.Model,
+		Prompt:        req.Prompt,
+		Tokens:        int(req.Tokens),
+		TopK:          40,
+		TopP:          0.95,
+		Temp:          0.1,
+		RepeatPenalty: 1.3,
+		RepeatLastN:   64,
+	}
+	s.Defaults.Apply(reqParams)
+	if req.TopK != nil {
+		reqParams.TopK = int(*req.TopK)
+	}
+	if req.RepeatLastn != nil {
+		reqParams.RepeatLastN = int(*req.RepeatLastn)
+	}
+	if req.TopP != nil {
+		reqParams.TopP = *req.TopP
+	}
+	if req.Temp != nil {
+		reqParams.Temp = *req.Temp
+	}
+	if req.RepeatPenalty != nil {
+		reqParams.RepeatPenalty = *req.RepeatPenalty
+	}
+	if req.Mirostat != nil {
+		reqParams.Mirostat = int(*req.Mirostat)
+	}
+	if req.MirostatTau != nil {
+		reqParams.MirostatTau = *req.MirostatTau
+	}
+	if req.MirostatEta != nil {
+		reqParams.MirostatEta = *req.MirostatEta
+	}
+	if req.MinP != nil {
+		reqParams.MinP = *req.MinP
+	}
+	if req.TypicalP != nil {
+		reqParams.TypicalP = *req.TypicalP
+	}
+	if req.TfsZ != nil {
+		reqParams.TfsZ = *req.TfsZ
+	}
+	if req.FrequencyPenalty != nil {
+		reqParams.FrequencyPenalty = *req.FrequencyPenalty
+	}
+	if req.PresencePenalty != nil {
+		reqParams.PresencePenalty = *req.PresencePenalty
+	}
+	if len(req.PenaltyExclude) > 0 {
+		reqParams.PenaltyExclude = nil
+		for _, id := range req.PenaltyExclude {
+			reqParams.PenaltyExclude = append(reqParams.PenaltyExclude, int(id))
+		}
+	}
+	if len(req.Samplers) > 0 {
+		reqParams.Samplers = req.Samplers
+	}
+	reqParams.SamplerPreset = req.SamplerPreset
+	reqParams.NumBeams = int(req.NumBeams)
+	reqParams.NumReturnBeams = int(req.NumReturnBeams)
+	if req.LengthPenalty != nil {
+		reqParams.LengthPenalty = *req.LengthPenalty
+	}
+	reqParams.EarlyStopping = req.EarlyStopping
+	reqParams.NegativePrompt = req.NegativePrompt
+	reqParams.CFGScale = req.CfgScale
+	if req.Instruct != nil {
+		reqParams.Instruct = *req.Instruct
+	}
+	reqParams.ContextShift = req.ContextShift
+	reqParams.ContextKeep = int(req.ContextKeep)
+	err := validateCompletion(reqParams, s.Defaults.SamplerPresets, s.Limits)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	wm, err := s.Pools.Get(reqParams.Model)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	pp := reqParams.ToPredictParams(s.Seed)
+	job := NewJob(CompletionJob, reqParams.Prompt, pp)
+	wm.DispatchJob(job)
+	// Stop generating when the client cancels or goes away
+	ctx := stream.Context()
+	stop := job.CancelOnDone(ctx)
+	defer stop()
+	for word := range job.Response {
+		err = stream.Send(&llamapb.CompleteResponse{
+			Text: word[0],
+		})
+		if err != nil {
+			// Drain the job so the worker client is not blocked
+			job.Cancel()
+			for range job.Response {
+			}
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if job.Err != nil {
+		return jobStatusErr(job.Err)
+	}
+	var beams []*llamapb.Beam
+	for _, beam := range job.Beams {
+		beams = append(beams, &llamapb.Beam{
+			Text:   beam.Text,
+			Tokens: int32(beam.Tokens),
+			Score:  beam.Score,
+			Reason: beam.Reason,
+		})
+	}
+	return stream.Send(&llamapb.CompleteResponse{
+		Finish: true,
+		Beams:  beams,
+		Reason: job.Reason,
+
+		ContextShifts: int32(job.ContextShifts),
+		Usage: &llamapb.Usage{
+			PromptTokens:     int32(job.Usage.PromptTokens),
+			CompletionTokens: int32(job.Usage.CompletionTokens),
+			TotalTokens:      int32(job.Usage.TotalTokens),
+		},
+		Timing: &llamapb.Timing{
+			QueueMs:           job.Timing.QueueMs,
+			PromptEvalMs:      job.Timing.PromptEvalMs,
+			SampleMsPerToken:  job.Timing.SampleMsPerToken,
+			PredictMsPerToken: job.Timing.PredictMsPerToken,
+			FirstTokenMs:      job.Timing.FirstTokenMs,
+			TokensPerSecond:   job.Timing.TokensPerSecond,
+		},
+	})
+}
+
+func (s *GRPCServer) Tokenize(ctx context.Context, req *llamapb.TokenizeRequest) (*llamapb.TokenizeResponse, error) {
+	if req.Prompt == "" {
+		return nil, status.Error(codes.InvalidArgument, "Require prompt")
+	}
+	if err := s.Limits.CheckPrompt(req.Prompt); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	wm, err := s.Pools.Get(req.Model)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	job := NewJob(TokenizeJob, req.Prompt, llama.DefaultPredictParams(512))
+	wm.DispatchJob(job)
+	var tokens []string
+	for words := range job.Response {
+		tokens = words
+	}
+	if job.Err != nil {
+		return nil, jobStatusErr(job.Err)
+	}
+	return &llamapb.TokenizeResponse{
+		Tokens: tokens,
+	}, nil
+}
+
+func (s *GRPCServer) Embed(ctx context.Context, req *llamapb.EmbedRequest) (*llamapb.EmbedResponse, error) {
+	if req.Prompt == "" {
+		return nil, status.Error(codes.InvalidArgument, "Require prompt")
+	}
+	if err := s.Limits.CheckPrompt(req.Prompt); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	wm, err := s.Pools.Get(req.Model)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	job := NewJob(EmbedJob, req.Prompt, llama.DefaultPredictParams(0))
+	wm.DispatchJob(job)
+	for range job.Response {
+	}
+	if job.Err != nil {
+		return nil, jobStatusErr(job.Err)
+	}
+	return &llamapb.EmbedResponse{
+		Embedding: job.Embedding,
+	}, nil
+}
+
+func (s *GRPCServer) ListModels(ctx context.Context, req *llamapb.ListModelsRequest) (*llamapb.ListModelsResponse, error) {
+	resp := &llamapb.ListModelsResponse{}
+	for _, info := range s.Pools.ModelInfos() {
+		resp.Models = append(resp.Models, &llamapb.ModelInfo{
+			Name:    info.Name,
+			Path:    info.Path,
+			CtxSize: int32(info.CtxSize),
+			Workers: int32(info.NumWorkers),
+			Threads: int32(info.Threads),
+			Default: info.Name == s.Pools.DefaultName(),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const unixAddrPrefix = "unix:"
+
+// listen creates a listener for addr. Addresses with a unix: prefix are
+// served from a unix socket, everything else is a TCP address.
+func listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixAddrPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	sockFile := strings.TrimPrefix(addr, unixAddrPrefix)
+	err := removeStaleSocket(sockFile)
+	if err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", sockFile)
+}
+
+// removeStaleSocket removes the socket file of a previous run, but never
+// other files.
+func removeStaleSocket(sockFile string) error {
+	info, err := os.Lstat(sockFile)
+	if err != nil {
+		return nil
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("Cannot listen on %s, the file exists and is not a socket", sockFile)
+	}
+	return os.Remove(sockFile)
+}
+
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+// certReloader keeps the current certificate and client CA pool, and
+// reloads them from disk when the process receives SIGHUP.
+type certReloader struct {
+	opts     TLSOptions
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+}
+
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("TLS requires both certificate and key file")
+	}
+	r := &certReloader{opts: opts}
+	err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	go r.watchSignal()
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}, nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("Cannot load certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		data, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("Cannot read client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("No certificate found in client CA file %s", r.opts.ClientCAFile)
+		}
+	}
+	r.lock.Lock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *certReloader) watchSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		err := r.load()
+		if err != nil {
+			log.Println("[TLS] Reload certificate got error, keep using the old one:", err)
+			continue
+		}
+		log.Println("[TLS] Certificate reloaded")
+	}
+}
+
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.clientCA != nil {
+		cfg.ClientCAs = r.clientCA
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
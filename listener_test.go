@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+
+	// A regular file is not replaced by the socket
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen(unixAddrPrefix + file); err == nil {
+		t.Error("regular file: want error")
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "data" {
+		t.Errorf("regular file changed: %q %v", data, err)
+	}
+
+	// The stale socket of a previous run is replaced
+	sockFile := filepath.Join(dir, "sock")
+	lis, err := net.Listen("unix", sockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	lis.Close()
+	lis, err = listen(unixAddrPrefix + sockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis.Close()
+}
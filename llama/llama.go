@@ -0,0 +1,562 @@
+// Package llama runs LLaMA models in ggml format in the current process.
+//
+// The C++ inference code is compiled by cgo, importing the package is
+// enough to use it:
+//
+//	model := llama.NewModel("./models/7B/ggml-model-q4_0.bin", 512, 4, 0)
+//	if err := model.Load(); err != nil {
+//		return err
+//	}
+//	defer model.Close()
+//	params := llama.DefaultPredictParams(64)
+//	_, err := model.PredictContext(ctx, params, "Hello", func(word string) {
+//		fmt.Print(word)
+//	})
+//
+// A model holds one context, calls on the same model run one at a time.
+package llama
+
+/*
+#cgo CFLAGS:   -I. -O3 -DNDEBUG -std=c17 -fPIC -pthread -mavx -mavx2 -mfma -mf16c -msse3
+#cgo CXXFLAGS: -O3 -DNDEBUG -std=c++17 -fPIC -pthread -I.
+
+#include <stdint.h>
+#include <stdlib.h>
+#include "main.h"
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/cgo"
+	"strconv"
+	"sync"
+	"unsafe"
+)
+
+const (
+	PROMPT_ERR    FinishReason = 0
+	PROMPT_FINISH FinishReason = 1
+	PROMPT_STOP   FinishReason = 2
+	PROMPT_CANCEL FinishReason = 3
+)
+
+type FinishReason int
+
+func (r FinishReason) String() string {
+	switch r {
+	case PROMPT_ERR:
+		return "Error"
+	case PROMPT_FINISH:
+		return "Finish"
+	case PROMPT_STOP:
+		return "Stop"
+	case PROMPT_CANCEL:
+		return "Cancel"
+	}
+	return "Unknown"
+}
+
+//export prompt_callback_bridge
+func prompt_callback_bridge(h C.uintptr_t, word *C.char) C.bool {
+	data := C.GoString(word)
+	fn := cgo.Handle(h).Value().(predictCallbackFn)
+	return C.bool(fn(data))
+}
+
+//export tokenizer_callback_bridge
+func tokenizer_callback_bridge(h C.uintptr_t, word *C.char) {
+	data := C.GoString(word)
+	fn := cgo.Handle(h).Value().(WordCallbackFn)
+	fn(data)
+}
+
+//export perplexity_callback_bridge
+func perplexity_callback_bridge(h C.uintptr_t, chunk C.int, nChunks C.int, ppl C.double) {
+	fn := cgo.Handle(h).Value().(PerplexityCallbackFn)
+	fn(PerplexityChunk{
+		Chunk:      int(chunk),
+		Chunks:     int(nChunks),
+		Perplexity: float64(ppl),
+	})
+}
+
+type WordCallbackFn func(data string)
+
+//export beam_callback_bridge
+func beam_callback_bridge(h C.uintptr_t, rank C.int, nTokens C.int, score C.double, eos C.bool, text *C.char) C.bool {
+	fn := cgo.Handle(h).Value().(beamCallbackFn)
+	if text == nil {
+		return C.bool(fn(nil))
+	}
+	reason := PROMPT_STOP
+	if eos {
+		reason = PROMPT_FINISH
+	}
+	return C.bool(fn(&Beam{
+		Text:   C.GoString(text),
+		Tokens: int(nTokens),
+		Score:  float64(score),
+		Reason: reason.String(),
+	}))
+}
+
+// predictCallbackFn returns false to stop the prediction.
+type predictCallbackFn func(data string) bool
+
+// beamCallbackFn is called with nil after each step of a beam search and
+// returns false to stop it, then with each beam.
+type beamCallbackFn func(beam *Beam) bool
+
+// PerplexityChunk is the running perplexity after a chunk of the text.
+type PerplexityChunk struct {
+	Chunk      int     `json:"chunk"`
+	Chunks     int     `json:"chunks"`
+	Perplexity float64 `json:"perplexity"`
+}
+
+type PerplexityCallbackFn func(chunk PerplexityChunk)
+
+// Strings encodes the chunk for the []string response stream of jobs.
+func (c PerplexityChunk) Strings() []string {
+	return []string{
+		strconv.Itoa(c.Chunk),
+		strconv.Itoa(c.Chunks),
+		strconv.FormatFloat(c.Perplexity, 'g', -1, 64),
+	}
+}
+
+func ParsePerplexityChunk(data []string) (PerplexityChunk, error) {
+	var (
+		ret PerplexityChunk
+		err error
+	)
+	if len(data) != 3 {
+		return ret, errors.New("Invalid perplexity chunk")
+	}
+	if ret.Chunk, err = strconv.Atoi(data[0]); err != nil {
+		return ret, err
+	}
+	if ret.Chunks, err = strconv.Atoi(data[1]); err != nil {
+		return ret, err
+	}
+	ret.Perplexity, err = strconv.ParseFloat(data[2], 64)
+	return ret, err
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Timing is the time spent on a completion. Per token times are averages
+// over the generated tokens.
+type Timing struct {
+	QueueMs           float64 `json:"queue_ms"`
+	PromptEvalMs      float64 `json:"prompt_eval_ms"`
+	SampleMsPerToken  float64 `json:"sample_ms_per_token"`
+	PredictMsPerToken float64 `json:"predict_ms_per_token"`
+	FirstTokenMs      float64 `json:"first_token_ms"`
+	TokensPerSecond   float64 `json:"tokens_per_second"`
+}
+
+type PredictResult struct {
+	Reason      FinishReason
+	Usage       Usage
+	Timing      Timing
+	MemPerToken int
+	// Beams of a beam search, the best first
+	Beams []Beam
+	// ContextShifts is the number of times the context was full and shifted
+	ContextShifts int
+}
+
+// Beam is a result of a beam search. The score is the sum of the log
+// probabilities of the tokens divided by the length to the power of the
+// length penalty.
+type Beam struct {
+	Text   string  `json:"text"`
+	Tokens int     `json:"tokens"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+type PredictParams struct {
+	Seed          int
+	Tokens        int
+	RepeatLastN   int
+	TopK          int
+	NBatch        int
+	TopP          float32
+	Temp          float32
+	RepeatPenalty float32
+	// Threads overrides the thread count of the model if positive
+	Threads   int  `json:",omitempty"`
+	IgnoreEOS bool `json:",omitempty"`
+	// Mirostat selects Mirostat sampling version 1 or 2 instead of top_k
+	// and top_p, zero tau and eta use the defaults
+	Mirostat    int     `json:",omitempty"`
+	MirostatTau float32 `json:",omitempty"`
+	MirostatEta float32 `json:",omitempty"`
+	// MinP, TypicalP and TfsZ are disabled when zero
+	MinP     float32 `json:",omitempty"`
+	TypicalP float32 `json:",omitempty"`
+	TfsZ     float32 `json:",omitempty"`
+	// Samplers replaces the fixed order of the samplers above if not
+	// empty, Mirostat takes precedence
+	Samplers []SamplerStage `json:",omitempty"`
+	// FrequencyPenalty and PresencePenalty are subtracted from the logits
+	// of the last RepeatLastN tokens, PenaltyExclude are token ids that
+	// none of the penalties apply to
+	FrequencyPenalty float32 `json:",omitempty"`
+	PresencePenalty  float32 `json:",omitempty"`
+	PenaltyExclude   []int   `json:",omitempty"`
+	// NumBeams above one runs a beam search instead of sampling, the
+	// result has the best NumReturnBeams beams. Zero LengthPenalty uses
+	// the default.
+	NumBeams       int     `json:",omitempty"`
+	NumReturnBeams int     `json:",omitempty"`
+	LengthPenalty  float32 `json:",omitempty"`
+	EarlyStopping  bool    `json:",omitempty"`
+	// CFGScale above one mixes in the logits of NegativePrompt with
+	// classifier-free guidance
+	NegativePrompt string  `json:",omitempty"`
+	CFGScale       float32 `json:",omitempty"`
+	// Instruct wraps the prompt in the instruction and response markers of
+	// Alpaca models and stops at the next instruction
+	Instruct bool `json:",omitempty"`
+	// ContextShift continues the generation once the context is full, the
+	// first ContextKeep tokens and the most recent half of the others are
+	// kept. Zero ContextKeep keeps the whole prompt.
+	ContextShift bool `json:",omitempty"`
+	ContextKeep  int  `json:",omitempty"`
+}
+
+const (
+	DefaultMirostatTau   = 5.0
+	DefaultMirostatEta   = 0.1
+	DefaultLengthPenalty = 1.0
+)
+
+func DefaultPredictParams(tokens int) PredictParams {
+	return PredictParams{
+		Seed:          -1,
+		Tokens:        tokens,
+		TopK:          40,
+		NBatch:        8,
+		TopP:          0.95,
+		Temp:          0.9,
+		RepeatPenalty: 1.10,
+		RepeatLastN:   64,
+	}
+}
+
+// ErrNotLoaded is returned by calls on a model that is not loaded or
+// already closed.
+var ErrNotLoaded = errors.New("Model is not loaded")
+
+// ErrPromptTooLong is returned when a prompt does not fit into the
+// context, the message of the returned error starts with its message.
+var ErrPromptTooLong = errors.New("Prompt is too long")
+
+type Model struct {
+	path    string
+	nctx    int
+	nParts  int
+	threads int
+
+	lock  sync.Mutex
+	state unsafe.Pointer
+}
+
+// NewModel creates a model that is loaded from path by Load. nParts 0
+// takes the number of parts from the model size.
+func NewModel(path string, nctx int, threads int, nParts int) *Model {
+	return &Model{
+		path:    path,
+		nctx:    nctx,
+		nParts:  nParts,
+		threads: threads,
+	}
+}
+
+func SystemInfo() string {
+	info := C.llama_print_system_info()
+	return C.GoString(info)
+}
+
+func (m *Model) Path() string {
+	return m.path
+}
+
+// ContextSize is the maximum number of prompt and generated tokens.
+func (m *Model) ContextSize() int {
+	return m.nctx
+}
+
+func (m *Model) Threads() int {
+	return m.threads
+}
+
+func (m *Model) Load() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state != nil {
+		return errors.New("Model is already loaded")
+	}
+	modelPath := C.CString(m.path)
+	defer C.free(unsafe.Pointer(modelPath))
+	state := C.llama_allocate_state()
+	fmt.Fprintf(os.Stderr, "Loading model %s...\n", m.path)
+	result := C.llama_bootstrap(modelPath, state, C.int(m.nctx), C.int(m.nParts), 0)
+	if result != 0 {
+		C.llama_free_state(state)
+		return errors.New("Bootstrap got error")
+	}
+	m.state = state
+	fmt.Fprintf(os.Stderr, "Model loaded successfully.\n")
+	return nil
+}
+
+// Close frees the memory of the model. It waits for the running call.
+func (m *Model) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state == nil {
+		return ErrNotLoaded
+	}
+	C.llama_free_state(m.state)
+	m.state = nil
+	return nil
+}
+
+func (m *Model) Predict(params PredictParams, text string, cb WordCallbackFn) (PredictResult, error) {
+	return m.PredictContext(context.Background(), params, text, cb)
+}
+
+// PredictContext calls cb with each generated word. It stops predicting
+// once ctx is done, the result has the PROMPT_CANCEL reason then.
+func (m *Model) PredictContext(ctx context.Context, params PredictParams, text string, cb WordCallbackFn) (PredictResult, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state == nil {
+		return PredictResult{Reason: PROMPT_ERR}, ErrNotLoaded
+	}
+	h := cgo.NewHandle(predictCallbackFn(func(word string) bool {
+		cb(word)
+		return ctx.Err() == nil
+	}))
+	defer h.Delete()
+	input := C.CString(text)
+	defer C.free(unsafe.Pointer(input))
+	negativePrompt := C.CString(params.NegativePrompt)
+	defer C.free(unsafe.Pointer(negativePrompt))
+	threads := m.threads
+	if params.Threads > 0 {
+		threads = params.Threads
+	}
+	mirostatTau, mirostatEta := params.MirostatTau, params.MirostatEta
+	if mirostatTau == 0 {
+		mirostatTau = DefaultMirostatTau
+	}
+	if mirostatEta == 0 {
+		mirostatEta = DefaultMirostatEta
+	}
+	var samplerTypes *C.int
+	var samplerValues *C.float
+	if len(params.Samplers) > 0 {
+		types := make([]C.int, len(params.Samplers))
+		values := make([]C.float, len(params.Samplers))
+		for i, stage := range params.Samplers {
+			types[i] = C.int(stage.Type)
+			values[i] = C.float(stage.Value)
+		}
+		samplerTypes, samplerValues = &types[0], &values[0]
+	}
+	var penaltyExclude *C.int
+	if len(params.PenaltyExclude) > 0 {
+		ids := make([]C.int, len(params.PenaltyExclude))
+		for i, id := range params.PenaltyExclude {
+			ids[i] = C.int(id)
+		}
+		penaltyExclude = &ids[0]
+	}
+	lengthPenalty := params.LengthPenalty
+	if lengthPenalty == 0 {
+		lengthPenalty = DefaultLengthPenalty
+	}
+	pparams := C.llama_allocate_params(input,
+		C.int(params.Seed),
+		C.int(threads),
+		C.int(params.Tokens),
+		C.int(params.TopK),
+		C.float(params.TopP),
+		C.float(params.Temp),
+		C.float(params.RepeatPenalty),
+		C.int(params.RepeatLastN),
+		C.int(params.NBatch),
+		C.bool(params.IgnoreEOS),
+		C.int(params.Mirostat),
+		C.float(mirostatTau),
+		C.float(mirostatEta),
+		C.float(params.MinP),
+		C.float(params.TypicalP),
+		C.float(params.TfsZ),
+		C.int(len(params.Samplers)),
+		samplerTypes,
+		samplerValues,
+		C.float(params.FrequencyPenalty),
+		C.float(params.PresencePenalty),
+		C.int(len(params.PenaltyExclude)),
+		penaltyExclude,
+		C.int(params.NumBeams),
+		C.float(lengthPenalty),
+		C.bool(params.EarlyStopping),
+		negativePrompt,
+		C.float(params.CFGScale),
+		C.bool(params.Instruct),
+		C.bool(params.ContextShift),
+		C.int(params.ContextKeep),
+	)
+	defer func() {
+		C.llama_free_params(pparams)
+	}()
+	var cres C.llama_predict_result
+	var result C.int
+	var beams []Beam
+	if params.NumBeams > 1 {
+		hb := cgo.NewHandle(beamCallbackFn(func(beam *Beam) bool {
+			if beam != nil {
+				beams = append(beams, *beam)
+			}
+			return ctx.Err() == nil
+		}))
+		defer hb.Delete()
+		result = C.llama_beam_search(pparams, m.state, C.uintptr_t(h), C.uintptr_t(hb), &cres)
+		n := params.NumReturnBeams
+		if n < 1 {
+			n = 1
+		}
+		if len(beams) > n {
+			beams = beams[:n]
+		}
+	} else {
+		result = C.llama_predict(pparams, m.state, C.uintptr_t(h), &cres)
+	}
+	ret := PredictResult{
+		Usage: Usage{
+			PromptTokens:     int(cres.prompt_tokens),
+			CompletionTokens: int(cres.completion_tokens),
+			TotalTokens:      int(cres.prompt_tokens + cres.completion_tokens),
+		},
+		Timing:      newTiming(&cres),
+		MemPerToken: int(cres.mem_per_token),
+		Beams:       beams,
+
+		ContextShifts: int(cres.n_context_shifts),
+	}
+	switch result {
+	case 0:
+		ret.Reason = PROMPT_STOP
+		return ret, nil
+	case 1:
+		ret.Reason = PROMPT_ERR
+		return ret, errors.New("Predicting failed")
+	case 2:
+		ret.Reason = PROMPT_FINISH
+		return ret, nil
+	case 3:
+		ret.Reason = PROMPT_CANCEL
+		return ret, nil
+	case 4:
+		ret.Reason = PROMPT_ERR
+		return ret, fmt.Errorf("%w, %d tokens do not fit into the context size %d", ErrPromptTooLong, ret.Usage.PromptTokens, m.nctx)
+	case 5:
+		ret.Reason = PROMPT_ERR
+		return ret, fmt.Errorf("%w, the negative prompt does not fit into the context size %d", ErrPromptTooLong, m.nctx)
+	}
+	ret.Reason = PROMPT_ERR
+	return ret, errors.New("Unknown result")
+}
+
+func newTiming(cres *C.llama_predict_result) Timing {
+	ret := Timing{
+		PromptEvalMs: float64(cres.t_prompt_eval_us) / 1000,
+		FirstTokenMs: float64(cres.t_first_token_us) / 1000,
+	}
+	if cres.completion_tokens > 0 {
+		ret.SampleMsPerToken = float64(cres.t_sample_us) / 1000 / float64(cres.completion_tokens)
+	}
+	if cres.n_predict > 0 {
+		ret.PredictMsPerToken = float64(cres.t_predict_us) / 1000 / float64(cres.n_predict)
+	}
+	genUs := cres.t_sample_us + cres.t_predict_us
+	if genUs > 0 {
+		ret.TokensPerSecond = float64(cres.completion_tokens) * 1e6 / float64(genUs)
+	}
+	return ret
+}
+
+// Perplexity evaluates the text in chunks of the context size and returns
+// the perplexity over all chunks.
+func (m *Model) Perplexity(text string, cb PerplexityCallbackFn) (float64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state == nil {
+		return 0, ErrNotLoaded
+	}
+	h := cgo.NewHandle(cb)
+	defer h.Delete()
+	input := C.CString(text)
+	defer C.free(unsafe.Pointer(input))
+	var ppl C.double
+	n := C.llama_perplexity(m.state, input, C.int(m.threads), C.uintptr_t(h), &ppl)
+	if n < 0 {
+		return 0, errors.New("Perplexity evaluation failed")
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("Text is too short, need at least %d tokens", m.nctx)
+	}
+	return float64(ppl), nil
+}
+
+func (m *Model) Embed(text string) ([]float32, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state == nil {
+		return nil, ErrNotLoaded
+	}
+	input := C.CString(text)
+	defer C.free(unsafe.Pointer(input))
+	nembd := int(C.llama_n_embd(m.state))
+	ret := make([]float32, nembd)
+	n := C.llama_embed(m.state, input, C.int(m.threads), (*C.float)(unsafe.Pointer(&ret[0])), C.int(nembd))
+	if n < 0 {
+		return nil, errors.New("Embedding failed")
+	}
+	return ret[:int(n)], nil
+}
+
+// Tokenize splits the prompt into the tokens of the model vocabulary, the
+// first token is the empty begin of text token.
+func (m *Model) Tokenize(prompt string) ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state == nil {
+		return nil, ErrNotLoaded
+	}
+	ret := []string{}
+	cb := func(word string) {
+		ret = append(ret, word)
+	}
+	h := cgo.NewHandle(WordCallbackFn(cb))
+	defer h.Delete()
+	input := C.CString(prompt)
+	defer C.free(unsafe.Pointer(input))
+	C.llama_tokenize_prompt(m.state, input, C.uintptr_t(h))
+	return ret, nil
+}
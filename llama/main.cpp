@@ -2,10 +2,12 @@
 #include "main.h"
 #include "utils.h"
 
+#include <algorithm>
 #include <cassert>
 #include <cinttypes>
 #include <cmath>
 #include <cstdio>
+#include <cctype>
 #include <cstring>
 #include <fstream>
 #include <iostream>
@@ -121,13 +123,19 @@ struct llama_model {
     struct ggml_tensor * memory_v;
 
     //
-    struct ggml_context * ctx;
+    struct ggml_context * ctx = nullptr;
     std::unordered_map<std::string, struct ggml_tensor *> tensors;
 };
 
 struct llama_state {
     llama_vocab vocab;
     llama_model model;
+
+    // key and value memory of the negative prompt of classifier-free
+    // guidance, allocated on first use
+    struct ggml_context * guidance_ctx = nullptr;
+    struct ggml_tensor * guidance_memory_k = nullptr;
+    struct ggml_tensor * guidance_memory_v = nullptr;
     struct {
         int64_t t_load_us = -1;
         int64_t t_sample_us = -1;
@@ -563,6 +571,7 @@ bool llama_model_load(const std::string & fname, llama_model & model, llama_voca
 //   - n_past:    the context size so far
 //   - embd_inp:  the embeddings of the tokens in the context
 //   - embd_w:    the predicted logits for the next token
+//   - embd_out:  if not null, the normalized hidden state of the last token
 //
 // The GPT-J model requires about 16MB of memory per input token.
 //
@@ -573,7 +582,8 @@ bool llama_eval(
         const std::vector<llama_vocab::id> & embd_inp,
               std::vector<float>           & embd_w,
               size_t                       & mem_per_token,
-              bool return_all_logits = false) {
+              bool return_all_logits = false,
+              std::vector<float>           * embd_out = nullptr) {
     const int N = embd_inp.size();
 
     const auto & hparams = model.hparams;
@@ -757,6 +767,8 @@ bool llama_eval(
                     inpL);
     }
 
+    struct ggml_tensor * embeddings = inpL;
+
     // lm_head
     {
         inpL = ggml_mul_mat(ctx0, model.output, inpL);
@@ -786,6 +798,11 @@ bool llama_eval(
         memcpy(embd_w.data(), (float *) ggml_get_data(inpL) + (n_vocab*(N-1)), sizeof(float)*n_vocab);
     }
 
+    if (embd_out != nullptr) {
+        embd_out->resize(n_embd);
+        memcpy(embd_out->data(), (float *) ggml_get_data(embeddings) + (n_embd*(N-1)), sizeof(float)*n_embd);
+    }
+
     if (mem_per_token == 0) {
         mem_per_token = ggml_used_mem(ctx0)/N;
     }
@@ -811,30 +828,27 @@ std::vector<double> softmax(const std::vector<float>& logits) {
     return probs;
 }
 
-void perplexity(const llama_vocab &vocab, const llama_model &model, const gpt_params &params, size_t mem_per_token) {
+// Returns the number of chunks evaluated or -1 on failure. The running
+// perplexity is reported to cb after each chunk.
+int perplexity(const llama_vocab &vocab, const llama_model &model, const std::string &text, int n_threads, size_t mem_per_token, uintptr_t cb, double *ppl) {
     // Download: https://s3.amazonaws.com/research.metamind.io/wikitext/wikitext-2-raw-v1.zip?ref=salesforce-research
-    // Run `./main --perplexity -m models/7B/ggml-model-q4_0.bin -f wiki.test.raw`
+    // Run `./llama-go -M perplexity -m models/7B/ggml-model-q4_0.bin -f wiki.test.raw`
     // Output: `perplexity: 13.5106 [114/114]`
-    std::vector<llama_vocab::id> tokens = ::llama_tokenize(vocab, params.prompt, true);
+    std::vector<llama_vocab::id> tokens = ::llama_tokenize(vocab, text, true);
 
+    const int n_ctx = model.hparams.n_ctx;
     int count = 0;
     double nll = 0.0;
-    int seq_count = tokens.size() / params.n_ctx;
-    printf("Calculating perplexity over %d chunks\n", seq_count);
+    int seq_count = tokens.size() / n_ctx;
+    *ppl = 0.0;
     for (int i = 0; i < seq_count; ++i) {
-        int start = i * params.n_ctx;
-        int end = start + params.n_ctx - 1;
+        int start = i * n_ctx;
+        int end = start + n_ctx - 1;
         std::vector<llama_vocab::id> embd(tokens.begin() + start, tokens.begin() + end);
         std::vector<float> logits;
-        auto start_t = std::chrono::high_resolution_clock::now();
-        if (!llama_eval(model, params.n_threads, 0, embd, logits, mem_per_token, true)) {
+        if (!llama_eval(model, n_threads, 0, embd, logits, mem_per_token, true)) {
             fprintf(stderr, "Failed to predict\n");
-            return;
-        }
-        auto end_t = std::chrono::high_resolution_clock::now();
-        if (i == 0) {
-            double seconds = std::chrono::duration<double>(end_t - start_t).count();
-            printf("%.2f seconds per pass - ETA %.2f hours\n", seconds, (seconds * seq_count) / (60.0*60.0));
+            return -1;
         }
         // We get the logits for all the tokens in the context window (params.n_ctx)
         // from llama_eval above.  Now, based on https://huggingface.co/docs/transformers/perplexity,
@@ -848,7 +862,7 @@ void perplexity(const llama_vocab &vocab, const llama_model &model, const gpt_pa
         // Example, we have a context window of 512, we will compute perplexity for each of the
         // last 256 tokens.  Then, we split the input up into context window size chunks to
         // process the entire prompt.
-        for (int j = params.n_ctx / 2; j < params.n_ctx - 1; ++j) {
+        for (int j = n_ctx / 2; j < n_ctx - 1; ++j) {
             // Calculate probability of next token, given the previous ones.
             int n_vocab = model.hparams.n_vocab;
             std::vector<float> tok_logits(
@@ -859,10 +873,12 @@ void perplexity(const llama_vocab &vocab, const llama_model &model, const gpt_pa
             ++count;
         }
         // perplexity is e^(average negative log-likelihood)
-        printf("[%d]%.4lf,", i + 1, std::exp(nll / count));
-        fflush(stdout);
+        *ppl = std::exp(nll / count);
+        if (cb) {
+            perplexity_callback_bridge(cb, i + 1, seq_count, *ppl);
+        }
     }
-    printf("\n");
+    return seq_count;
 }
 
 static bool is_interacting = false;
@@ -921,11 +937,101 @@ int llama_bootstrap(const char *model_path, void* state_pr, int32_t n_ctx, int32
         return 0;
 }
 
-int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb) {
+// tokenize the prompt, in instruct mode it is wrapped in the instruction
+// and response markers and the generation stops at the next instruction
+static std::vector<llama_vocab::id> llama_prompt_tokens(const llama_vocab & vocab, gpt_params & params) {
+    // Add a space in front of the first character to match OG llama tokenizer behavior
+    params.prompt.insert(0, 1, ' ');
+
+    // prefix & suffix for instruct mode
+    const std::vector<llama_vocab::id> inp_pfx = ::llama_tokenize(vocab, "\n\n### Instruction:\n\n", true);
+    const std::vector<llama_vocab::id> inp_sfx = ::llama_tokenize(vocab, "\n\n### Response:\n\n", false);
+
+    // in instruct mode, we inject a prefix and a suffix to each input by the user
+    if (params.instruct) {
+        params.interactive = true;
+        params.antiprompt.push_back("### Instruction:");
+
+        std::vector<llama_vocab::id> embd_inp = inp_pfx;
+        const std::vector<llama_vocab::id> prompt_inp = ::llama_tokenize(vocab, params.prompt, false);
+        embd_inp.insert(embd_inp.end(), prompt_inp.begin(), prompt_inp.end());
+        embd_inp.insert(embd_inp.end(), inp_sfx.begin(), inp_sfx.end());
+        return embd_inp;
+    }
+
+    // enable interactive mode if reverse prompt is specified
+    if (params.antiprompt.size() != 0) {
+        params.interactive = true;
+    }
+
+    // tokenize the prompt
+    return ::llama_tokenize(vocab, params.prompt, true);
+}
+
+// number of bytes at the end of text that may be the start of a reverse
+// prompt, including the whitespace in front of it
+static size_t llama_antiprompt_partial(const std::string & text, const std::vector<std::string> & antiprompt) {
+    if (antiprompt.empty()) {
+        return 0;
+    }
+    size_t n = 0;
+    for (const auto & ap : antiprompt) {
+        for (size_t len = std::min(ap.size() - 1, text.size()); len > n; len--) {
+            if (text.compare(text.size() - len, len, ap, 0, len) == 0) {
+                n = len;
+                break;
+            }
+        }
+    }
+    while (n < text.size() && isspace((unsigned char) text[text.size() - n - 1])) {
+        n++;
+    }
+    return n;
+}
+
+// allocate the second key and value memory used by the negative prompt
+static bool llama_guidance_init(llama_state & state) {
+    if (state.guidance_ctx) {
+        return true;
+    }
+    const llama_model & model = state.model;
+    const size_t n_elements = ggml_nelements(model.memory_k);
+
+    struct ggml_init_params params = {
+        /*.mem_size   =*/ ggml_nbytes(model.memory_k) + ggml_nbytes(model.memory_v) + 1024*1024,
+        /*.mem_buffer =*/ NULL,
+    };
+
+    state.guidance_ctx = ggml_init(params);
+    if (!state.guidance_ctx) {
+        fprintf(stderr, "%s: ggml_init() failed\n", __func__);
+        return false;
+    }
+    state.guidance_memory_k = ggml_new_tensor_1d(state.guidance_ctx, model.memory_k->type, n_elements);
+    state.guidance_memory_v = ggml_new_tensor_1d(state.guidance_ctx, model.memory_v->type, n_elements);
+    return true;
+}
+
+// evaluate the tokens of the negative prompt with the guidance memory
+static bool llama_guidance_eval(llama_state & state, const int n_threads, const int n_past,
+                                const std::vector<llama_vocab::id> & embd_inp, std::vector<float> & embd_w, size_t & mem_per_token) {
+    llama_model & model = state.model;
+    std::swap(model.memory_k, state.guidance_memory_k);
+    std::swap(model.memory_v, state.guidance_memory_v);
+    const bool ok = llama_eval(model, n_threads, n_past, embd_inp, embd_w, mem_per_token);
+    std::swap(model.memory_k, state.guidance_memory_k);
+    std::swap(model.memory_v, state.guidance_memory_v);
+    return ok;
+}
+
+int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb, llama_predict_result* result) {
     gpt_params params = *(gpt_params*) params_ptr;
-    llama_state state = *(llama_state*) state_pr;
-    llama_vocab vocab = state.vocab;
-    llama_model model = state.model;
+    llama_state & state = *(llama_state*) state_pr;
+    llama_vocab & vocab = state.vocab;
+    llama_model & model = state.model;
+
+    const int64_t t_start_predict_us = ggml_time_us();
+    *result = {};
 
     if (params.seed < 0) {
         params.seed = time(NULL);
@@ -936,44 +1042,74 @@ int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb) {
     // determine the required inference memory per token:
     size_t mem_per_token = 0;
     llama_eval(model, params.n_threads, 0, { 0, 1, 2, 3 }, logits, mem_per_token);
-
-    if (params.perplexity) {
-        perplexity(vocab, model, params, mem_per_token);
-        exit(0);
-    }
+    result->mem_per_token = mem_per_token;
     int n_past = 0;
 
     state.timing.t_sample_us = 0;
     state.timing.t_predict_us = 0;
 
-    // Add a space in front of the first character to match OG llama tokenizer behavior
-    params.prompt.insert(0, 1, ' ');
-    // tokenize the prompt
-    std::vector<llama_vocab::id> embd_inp = ::llama_tokenize(vocab, params.prompt, true);
+    std::vector<llama_vocab::id> embd_inp = llama_prompt_tokens(vocab, params);
 
-    params.n_predict = std::min(params.n_predict, model.hparams.n_ctx - (int) embd_inp.size());
+    result->prompt_tokens = embd_inp.size();
 
-    // prefix & suffix for instruct mode
-    const std::vector<llama_vocab::id> inp_pfx = ::llama_tokenize(vocab, "\n\n### Instruction:\n\n", true);
-    const std::vector<llama_vocab::id> inp_sfx = ::llama_tokenize(vocab, "\n\n### Response:\n\n", false);
+    const int n_ctx = model.hparams.n_ctx;
 
-    // in instruct mode, we inject a prefix and a suffix to each input by the user
-    if (params.instruct) {
-        params.interactive = true;
-        params.antiprompt.push_back("### Instruction:\n\n");
+    // the guidance memory is not shifted
+    int n_keep = 0;
+    if (params.context_shift && params.cfg_scale <= 1.0f && (int) embd_inp.size() < n_ctx) {
+        n_keep = params.n_keep > 0 ? params.n_keep : (int) embd_inp.size();
+        n_keep = std::max(1, std::min({ n_keep, (int) embd_inp.size(), n_ctx/2 }));
+    } else {
+        params.context_shift = false;
+        params.n_predict = std::min(params.n_predict, n_ctx - (int) embd_inp.size());
     }
+    // tokens in the context, kept for the re-evaluation when it is shifted
+    std::vector<llama_vocab::id> context_tokens;
+
+    // classifier-free guidance evaluates the negative prompt and the generated
+    // tokens in a second memory
+    const bool guidance = params.cfg_scale > 1.0f;
+    std::vector<llama_vocab::id> guidance_inp;
+    std::vector<float> guidance_logits;
+    int guidance_n_past = 0;
+    if (guidance) {
+        guidance_inp = ::llama_tokenize(vocab, " " + params.negative_prompt, true);
+        if ((int) guidance_inp.size() >= n_ctx) {
+            // the negative prompt does not fit into the guidance memory
+            return 5;
+        }
+        if (!llama_guidance_init(state)) {
+            return 1;
+        }
+        params.n_predict = std::min(params.n_predict, n_ctx - (int) guidance_inp.size());
 
-    // enable interactive mode if reverse prompt is specified
-    if (params.antiprompt.size() != 0) {
-        params.interactive = true;
+        const int64_t t_start_us = ggml_time_us();
+        for (size_t i = 0; i < guidance_inp.size(); i += params.n_batch) {
+            const size_t n = std::min(guidance_inp.size() - i, (size_t) params.n_batch);
+            const std::vector<llama_vocab::id> batch(guidance_inp.begin() + i, guidance_inp.begin() + i + n);
+            if (!llama_guidance_eval(state, params.n_threads, guidance_n_past, batch, guidance_logits, mem_per_token)) {
+                return 1;
+            }
+            guidance_n_past += n;
+        }
+        result->t_prompt_eval_us += ggml_time_us() - t_start_us;
     }
 
+    // text that may be the start of a reverse prompt is held back until it is known
+    std::string pending;
+    auto emit = [&](const std::string & text) {
+        return text.empty() || prompt_callback_bridge(cb, const_cast<char*>(text.c_str()));
+    };
 
     std::vector<llama_vocab::id> embd;
 
     int last_n_size = params.repeat_last_n;
     std::vector<llama_vocab::id> last_n_tokens(last_n_size);
     std::fill(last_n_tokens.begin(), last_n_tokens.end(), 0);
+    // number of last_n_tokens that are not the initial padding
+    int n_last = 0;
+    // number of last_n_tokens that were sampled, they follow the prompt
+    int n_sampled = 0;
 
     int input_consumed = 0;
     bool input_noecho = false;
@@ -981,20 +1117,62 @@ int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb) {
     int remaining_tokens = params.n_predict;
 
     int input_size = embd_inp.size();
+    bool embd_is_prompt = false;
+
+    // mirostat target surprise, adjusted after every sampled token
+    double mirostat_mu = 2.0 * params.mirostat_tau;
 
     while (remaining_tokens > 0) {
         // predict
         if (embd.size() > 0) {
+            // the context is full, keep the first n_keep tokens and evaluate
+            // the most recent half of the others again
+            if (params.context_shift && n_past + (int) embd.size() > n_ctx) {
+                const int n_left = n_past - n_keep;
+                const std::vector<llama_vocab::id> recent(context_tokens.end() - n_left/2, context_tokens.end());
+                context_tokens.resize(n_keep);
+                n_past = n_keep;
+
+                const int64_t t_start_us = ggml_time_us();
+                for (size_t i = 0; i < recent.size(); i += params.n_batch) {
+                    const size_t n = std::min(recent.size() - i, (size_t) params.n_batch);
+                    const std::vector<llama_vocab::id> batch(recent.begin() + i, recent.begin() + i + n);
+                    if (!llama_eval(model, params.n_threads, n_past, batch, logits, mem_per_token)) {
+                        return 1;
+                    }
+                    n_past += n;
+                }
+                context_tokens.insert(context_tokens.end(), recent.begin(), recent.end());
+                result->t_prompt_eval_us += ggml_time_us() - t_start_us;
+                ++result->n_context_shifts;
+            }
+
             const int64_t t_start_us = ggml_time_us();
 
             if (!llama_eval(model, params.n_threads, n_past, embd, logits, mem_per_token)) {
                 return 1;
             }
+            if (guidance && !embd_is_prompt) {
+                if (!llama_guidance_eval(state, params.n_threads, guidance_n_past, embd, guidance_logits, mem_per_token)) {
+                    return 1;
+                }
+                guidance_n_past += embd.size();
+            }
 
-            state.timing.t_predict_us += ggml_time_us() - t_start_us;
+            const int64_t t_eval_us = ggml_time_us() - t_start_us;
+            state.timing.t_predict_us += t_eval_us;
+            if (embd_is_prompt) {
+                result->t_prompt_eval_us += t_eval_us;
+            } else {
+                result->t_predict_us += t_eval_us;
+                ++result->n_predict;
+            }
         }
 
         n_past += embd.size();
+        if (params.context_shift) {
+            context_tokens.insert(context_tokens.end(), embd.begin(), embd.end());
+        }
         embd.clear();
 
         if ((int) embd_inp.size() <= input_consumed) {
@@ -1011,19 +1189,57 @@ int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb) {
             {
                 const int64_t t_start_sample_us = ggml_time_us();
 
+                if (guidance) {
+                    sample_guidance(logits.data() + (logits.size() - n_vocab), guidance_logits.data() + (guidance_logits.size() - n_vocab),
+                                    n_vocab, params.cfg_scale);
+                }
+
                 if (params.ignore_eos) {
                     // set the logit of the eos token to zero to avoid sampling it
                     logits[logits.size() - n_vocab + EOS_TOKEN_ID] = 0;
                 }
 
-                id = llama_sample_top_p_top_k(vocab, logits.data() + (logits.size() - n_vocab), last_n_tokens, repeat_penalty, top_k, top_p, temp, rng);
+                // the penalties skip the padding and the excluded tokens like newline
+                auto penalty_window = [&](int n) {
+                    std::vector<llama_vocab::id> ret(last_n_tokens.end() - n, last_n_tokens.end());
+                    if (!params.penalty_exclude.empty()) {
+                        ret.erase(std::remove_if(ret.begin(), ret.end(), [&](llama_vocab::id t) {
+                            return std::find(params.penalty_exclude.begin(), params.penalty_exclude.end(), t) != params.penalty_exclude.end();
+                        }), ret.end());
+                    }
+                    return ret;
+                };
+                std::vector<llama_vocab::id> penalty_tokens = penalty_window(n_last);
+
+                // like the OpenAI API the frequency and presence penalties
+                // count the generated tokens only, not the prompt
+                sample_frequency_presence(logits.data() + (logits.size() - n_vocab), n_vocab, penalty_window(n_sampled),
+                                          params.frequency_penalty, params.presence_penalty);
+
+                if (params.mirostat > 0) {
+                    id = llama_sample_mirostat(vocab, logits.data() + (logits.size() - n_vocab), penalty_tokens, repeat_penalty, temp,
+                                               params.mirostat, params.mirostat_tau, params.mirostat_eta, mirostat_mu, rng);
+                } else if (!params.samplers.empty()) {
+                    id = llama_sample_chain(vocab, logits.data() + (logits.size() - n_vocab), penalty_tokens, params.samplers, rng);
+                } else {
+                    id = llama_sample_top_p_top_k(vocab, logits.data() + (logits.size() - n_vocab), penalty_tokens, repeat_penalty, top_k, top_p,
+                                                  params.min_p, params.typical_p, params.tfs_z, temp, rng);
+                }
 
                 last_n_tokens.erase(last_n_tokens.begin());
                 last_n_tokens.push_back(id);
+                n_last = std::min(n_last + 1, last_n_size);
+                n_sampled = std::min(n_sampled + 1, last_n_size);
 
                 state.timing.t_sample_us += ggml_time_us() - t_start_sample_us;
+                result->t_sample_us += ggml_time_us() - t_start_sample_us;
             }
 
+            if (result->completion_tokens == 0) {
+                result->t_first_token_us = ggml_time_us() - t_start_predict_us;
+            }
+            embd_is_prompt = false;
+
             // add it to the context
             embd.push_back(id);
 
@@ -1032,12 +1248,15 @@ int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb) {
 
             // decrement remaining sampling budget
             --remaining_tokens;
+            ++result->completion_tokens;
         } else {
             // some user input remains from prompt or interaction, forward it to processing
+            embd_is_prompt = true;
             while ((int) embd_inp.size() > input_consumed) {
                 embd.push_back(embd_inp[input_consumed]);
                 last_n_tokens.erase(last_n_tokens.begin());
                 last_n_tokens.push_back(embd_inp[input_consumed]);
+                n_last = std::min(n_last + 1, last_n_size);
                 ++input_consumed;
                 if ((int) embd.size() >= params.n_batch) {
                     break;
@@ -1056,26 +1275,311 @@ int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb) {
                         input_size--;
                         continue;
                     }
-                    const char * word = vocab.id_to_token[id].tok.c_str();
-                    prompt_callback_bridge(cb, const_cast<char*>(word));
+                    pending += vocab.id_to_token[id].tok;
+
+                    // stop at a reverse prompt like the next instruction
+                    size_t pos = std::string::npos;
+                    for (const auto & ap : params.antiprompt) {
+                        pos = std::min(pos, pending.find(ap));
+                    }
+                    if (pos != std::string::npos) {
+                        pending.erase(pos);
+                        while (!pending.empty() && isspace((unsigned char) pending.back())) {
+                            pending.pop_back();
+                        }
+                        result->t_total_us = ggml_time_us() - t_start_predict_us;
+                        return emit(pending) ? 2 : 3;
+                    }
+
+                    const size_t keep = llama_antiprompt_partial(pending, params.antiprompt);
+                    if (!emit(pending.substr(0, pending.size() - keep))) {
+                        // cancelled by the caller
+                        result->t_total_us = ggml_time_us() - t_start_predict_us;
+                        return 3;
+                    }
+                    pending.erase(0, pending.size() - keep);
                 }
             }
         }
 
         // end of text token
         if (embd.back() == EOS_TOKEN_ID) {
-            return 2;
+            result->t_total_us = ggml_time_us() - t_start_predict_us;
+            return emit(pending) ? 2 : 3;
+        }
+    }
+    result->t_total_us = ggml_time_us() - t_start_predict_us;
+    return emit(pending) ? 0 : 3;
+}
+
+struct llama_beam {
+    std::vector<llama_vocab::id> tokens; // generated tokens
+    double logprob = 0.0;                // sum of the log probabilities of the tokens
+    std::vector<uint8_t> kv;             // key and value memory of the generated tokens
+    std::vector<float> logits;           // logits of the next token
+};
+
+struct llama_hypothesis {
+    std::vector<llama_vocab::id> tokens;
+    double score;
+    bool eos;
+};
+
+// normalize the log probability of a beam by its length
+static double llama_beam_score(double logprob, int length, double length_penalty) {
+    return logprob / pow(std::max(length, 1), length_penalty);
+}
+
+// save or restore the key and value memory of the positions [n_past, n_past + n)
+static void llama_kv_copy(const llama_model & model, int n_past, int n, std::vector<uint8_t> & buf, bool save) {
+    const auto & hparams = model.hparams;
+    const size_t row   = ggml_element_size(model.memory_k)*hparams.n_embd;
+    const size_t layer = row*n;
+    if (save) {
+        buf.resize(2*hparams.n_layer*layer);
+    }
+    for (int il = 0; il < hparams.n_layer; ++il) {
+        uint8_t * k  = (uint8_t *) model.memory_k->data + row*(il*hparams.n_ctx + n_past);
+        uint8_t * v  = (uint8_t *) model.memory_v->data + row*(il*hparams.n_ctx + n_past);
+        uint8_t * bk = buf.data() + 2*il*layer;
+        uint8_t * bv = bk + layer;
+        if (save) {
+            memcpy(bk, k, layer);
+            memcpy(bv, v, layer);
+        } else {
+            memcpy(k, bk, layer);
+            memcpy(v, bv, layer);
         }
     }
-    return 0;
+}
+
+int llama_beam_search(void* params_ptr, void* state_pr, uintptr_t cb, uintptr_t beam_cb, llama_predict_result* result) {
+    gpt_params params = *(gpt_params*) params_ptr;
+    llama_state & state = *(llama_state*) state_pr;
+    llama_vocab & vocab = state.vocab;
+    llama_model & model = state.model;
+
+    const int64_t t_start_predict_us = ggml_time_us();
+    *result = {};
+
+    const int n_vocab   = model.hparams.n_vocab;
+    const int num_beams = params.num_beams;
+
+    // determine the required inference memory per token:
+    std::vector<float> logits;
+    size_t mem_per_token = 0;
+    llama_eval(model, params.n_threads, 0, { 0, 1, 2, 3 }, logits, mem_per_token);
+    result->mem_per_token = mem_per_token;
+
+    std::vector<llama_vocab::id> embd_inp = llama_prompt_tokens(vocab, params);
+    const int n_prompt = embd_inp.size();
+    result->prompt_tokens = n_prompt;
+    if (n_prompt >= model.hparams.n_ctx) {
+        // the prompt does not fit into the memory
+        return 4;
+    }
+
+    params.n_predict = std::min(params.n_predict, model.hparams.n_ctx - n_prompt);
+
+    // evaluate the prompt once, its memory is shared by all beams
+    for (int i = 0; i < n_prompt; i += params.n_batch) {
+        std::vector<llama_vocab::id> embd(embd_inp.begin() + i, embd_inp.begin() + std::min(i + params.n_batch, n_prompt));
+        if (!llama_eval(model, params.n_threads, i, embd, logits, mem_per_token)) {
+            return 1;
+        }
+    }
+    result->t_prompt_eval_us = ggml_time_us() - t_start_predict_us;
+
+    std::vector<llama_beam> beams(1);
+    beams[0].logits.assign(logits.end() - n_vocab, logits.end());
+
+    std::vector<llama_hypothesis> finished;
+    bool done = false;
+
+    struct candidate {
+        int beam;
+        llama_vocab::id token;
+        double logprob;
+    };
+
+    for (int n_gen = 0; n_gen < params.n_predict && !done; ++n_gen) {
+        if (!beam_callback_bridge(beam_cb, -1, 0, 0.0, false, nullptr)) {
+            // cancelled by the caller
+            result->t_total_us = ggml_time_us() - t_start_predict_us;
+            return 3;
+        }
+
+        const int64_t t_start_sample_us = ggml_time_us();
+
+        // extend each beam by its most likely tokens
+        std::vector<candidate> candidates;
+        for (int b = 0; b < (int) beams.size(); ++b) {
+            std::vector<float> & bl = beams[b].logits;
+            if (params.ignore_eos) {
+                bl[EOS_TOKEN_ID] = -INFINITY;
+            }
+            double maxl = -INFINITY;
+            for (float l : bl) {
+                maxl = std::max(maxl, (double) l);
+            }
+            double sum = 0.0;
+            for (float l : bl) {
+                sum += exp(l - maxl);
+            }
+            const double log_sum = maxl + log(sum);
+
+            std::vector<std::pair<double, llama_vocab::id>> top;
+            top.reserve(n_vocab);
+            for (int i = 0; i < n_vocab; ++i) {
+                top.push_back(std::make_pair(bl[i], i));
+            }
+            sample_top_k(top, std::min(2*num_beams, n_vocab));
+            for (const auto & kv : top) {
+                candidates.push_back({ b, kv.second, beams[b].logprob + kv.first - log_sum });
+            }
+        }
+        std::stable_sort(candidates.begin(), candidates.end(), [](const candidate & a, const candidate & b) {
+            return a.logprob > b.logprob;
+        });
+
+        std::vector<candidate> next;
+        for (int i = 0; i < (int) candidates.size() && (int) next.size() < num_beams; ++i) {
+            const candidate & c = candidates[i];
+            if (c.token == EOS_TOKEN_ID) {
+                // only the top candidates finish a beam
+                if (i < num_beams) {
+                    const auto & tokens = beams[c.beam].tokens;
+                    finished.push_back({ tokens, llama_beam_score(c.logprob, tokens.size() + 1, params.length_penalty), true });
+                }
+                continue;
+            }
+            next.push_back(c);
+        }
+
+        std::stable_sort(finished.begin(), finished.end(), [](const llama_hypothesis & a, const llama_hypothesis & b) {
+            return a.score > b.score;
+        });
+        if ((int) finished.size() > num_beams) {
+            finished.resize(num_beams);
+        }
+
+        state.timing.t_sample_us += ggml_time_us() - t_start_sample_us;
+        result->t_sample_us += ggml_time_us() - t_start_sample_us;
+
+        if ((int) finished.size() >= num_beams) {
+            // without early stopping continue while a running beam can still beat the worst finished one
+            done = params.early_stopping || next.empty() ||
+                finished.back().score >= llama_beam_score(next[0].logprob, n_gen + 1, params.length_penalty);
+        }
+        if (next.empty()) {
+            done = true;
+        }
+        if (done) {
+            break;
+        }
+
+        // evaluate the new token of each beam, the children of a beam share its restored memory
+        std::stable_sort(next.begin(), next.end(), [](const candidate & a, const candidate & b) {
+            return a.beam < b.beam;
+        });
+        std::vector<llama_beam> new_beams;
+        int restored = -1;
+        for (const candidate & c : next) {
+            llama_beam & parent = beams[c.beam];
+            if (restored != c.beam && n_gen > 0) {
+                llama_kv_copy(model, n_prompt, n_gen, parent.kv, false);
+            }
+            restored = c.beam;
+
+            const int64_t t_start_us = ggml_time_us();
+            if (!llama_eval(model, params.n_threads, n_prompt + n_gen, { c.token }, logits, mem_per_token)) {
+                return 1;
+            }
+            const int64_t t_eval_us = ggml_time_us() - t_start_us;
+            state.timing.t_predict_us += t_eval_us;
+            result->t_predict_us += t_eval_us;
+            ++result->n_predict;
+
+            llama_beam child;
+            child.tokens = parent.tokens;
+            child.tokens.push_back(c.token);
+            child.logprob = c.logprob;
+            child.logits.assign(logits.end() - n_vocab, logits.end());
+            llama_kv_copy(model, n_prompt, n_gen + 1, child.kv, true);
+            new_beams.push_back(std::move(child));
+        }
+        beams = std::move(new_beams);
+
+        if (n_gen == 0) {
+            result->t_first_token_us = ggml_time_us() - t_start_predict_us;
+        }
+    }
+
+    // beams that reached the token limit
+    if (!done) {
+        for (const auto & beam : beams) {
+            finished.push_back({ beam.tokens, llama_beam_score(beam.logprob, beam.tokens.size(), params.length_penalty), false });
+        }
+        std::stable_sort(finished.begin(), finished.end(), [](const llama_hypothesis & a, const llama_hypothesis & b) {
+            return a.score > b.score;
+        });
+        if ((int) finished.size() > num_beams) {
+            finished.resize(num_beams);
+        }
+    }
+
+    if (finished.empty()) {
+        return 1;
+    }
+
+    // report the beams, the best one first
+    for (int i = 0; i < (int) finished.size(); ++i) {
+        std::string text;
+        for (auto id : finished[i].tokens) {
+            text += vocab.id_to_token[id].tok;
+        }
+        const int n_tokens = finished[i].tokens.size() + (finished[i].eos ? 1 : 0);
+        beam_callback_bridge(beam_cb, i, n_tokens, finished[i].score, finished[i].eos, const_cast<char*>(text.c_str()));
+    }
+
+    const llama_hypothesis & best = finished[0];
+    result->completion_tokens = best.tokens.size() + (best.eos ? 1 : 0);
+    for (auto id : best.tokens) {
+        if (!prompt_callback_bridge(cb, const_cast<char*>(vocab.id_to_token[id].tok.c_str()))) {
+            // cancelled by the caller
+            result->t_total_us = ggml_time_us() - t_start_predict_us;
+            return 3;
+        }
+    }
+
+    result->t_total_us = ggml_time_us() - t_start_predict_us;
+    return best.eos ? 2 : 0;
 }
 
 void* llama_allocate_state() {
-    return new llama_state;
+    return new llama_state();
+}
+
+void llama_free_state(void* state_ptr) {
+    llama_state* state = (llama_state*) state_ptr;
+    if (state->model.ctx) {
+        ggml_free(state->model.ctx);
+    }
+    if (state->guidance_ctx) {
+        ggml_free(state->guidance_ctx);
+    }
+    delete state;
 }
 
 void* llama_allocate_params(const char *prompt, int seed, int threads, int tokens, int top_k,
-                            float top_p, float temp, float repeat_penalty, int repeat_last_n, int n_batch) {
+                            float top_p, float temp, float repeat_penalty, int repeat_last_n, int n_batch, bool ignore_eos,
+                            int mirostat, float mirostat_tau, float mirostat_eta,
+                            float min_p, float typical_p, float tfs_z,
+                            int n_samplers, const int* sampler_types, const float* sampler_values,
+                            float frequency_penalty, float presence_penalty, int n_penalty_exclude, const int* penalty_exclude,
+                            int num_beams, float length_penalty, bool early_stopping,
+                            const char *negative_prompt, float cfg_scale, bool instruct,
+                            bool context_shift, int n_keep) {
     gpt_params* params = new gpt_params;
     params->seed = seed;
     params->n_threads = threads;
@@ -1086,9 +1590,30 @@ void* llama_allocate_params(const char *prompt, int seed, int threads, int token
     params->top_p = top_p;
     params->temp = temp;
     params->repeat_penalty = repeat_penalty;
+    params->mirostat = mirostat;
+    params->mirostat_tau = mirostat_tau;
+    params->mirostat_eta = mirostat_eta;
+    params->min_p = min_p;
+    params->typical_p = typical_p;
+    params->tfs_z = tfs_z;
+    for (int i = 0; i < n_samplers; i++) {
+        params->samplers.push_back({ (llama_sampler_type) sampler_types[i], sampler_values[i] });
+    }
+    params->frequency_penalty = frequency_penalty;
+    params->presence_penalty = presence_penalty;
+    params->penalty_exclude.assign(penalty_exclude, penalty_exclude + n_penalty_exclude);
+    params->num_beams = num_beams;
+    params->length_penalty = length_penalty;
+    params->early_stopping = early_stopping;
+    params->negative_prompt = negative_prompt;
+    params->cfg_scale = cfg_scale;
+    params->instruct = instruct;
+    params->context_shift = context_shift;
+    params->n_keep = n_keep;
 
     params->prompt = prompt;
     params->n_batch = n_batch;
+    params->ignore_eos = ignore_eos;
     return params;
 }
 
@@ -1097,6 +1622,52 @@ void llama_free_params(void* params_ptr) {
     delete params;
 }
 
+int llama_embed(void* state_pr, const char* prompt, int threads, float* out, int n_out) {
+    llama_state* state = (llama_state*) state_pr;
+    const llama_model & model = state->model;
+
+    std::string text = prompt;
+    // Add a space in front of the first character to match OG llama tokenizer behavior
+    text.insert(0, 1, ' ');
+    std::vector<llama_vocab::id> embd_inp = ::llama_tokenize(state->vocab, text, true);
+    if ((int) embd_inp.size() > model.hparams.n_ctx) {
+        embd_inp.resize(model.hparams.n_ctx);
+    }
+
+    std::vector<float> logits;
+    std::vector<float> embeddings;
+
+    // determine the required inference memory per token:
+    size_t mem_per_token = 0;
+    llama_eval(model, threads, 0, { 0, 1, 2, 3 }, logits, mem_per_token);
+
+    if (!llama_eval(model, threads, 0, embd_inp, logits, mem_per_token, false, &embeddings)) {
+        return -1;
+    }
+
+    int n = std::min(n_out, (int) embeddings.size());
+    memcpy(out, embeddings.data(), sizeof(float)*n);
+    return n;
+}
+
+int llama_perplexity(void* state_pr, const char* text, int threads, uintptr_t cb, double* ppl) {
+    llama_state* state = (llama_state*) state_pr;
+    const llama_model & model = state->model;
+
+    std::vector<float> logits;
+
+    // determine the required inference memory per token:
+    size_t mem_per_token = 0;
+    llama_eval(model, threads, 0, { 0, 1, 2, 3 }, logits, mem_per_token);
+
+    return perplexity(state->vocab, model, text, threads, mem_per_token, cb, ppl);
+}
+
+int llama_n_embd(void* state_pr) {
+    llama_state* state = (llama_state*) state_pr;
+    return state->model.hparams.n_embd;
+}
+
 void llama_tokenize_prompt(void* state_ptr, const char* prompt, uintptr_t cb) {
     llama_state state = *(llama_state*) state_ptr;
     llama_vocab vocab = state.vocab;
@@ -0,0 +1,69 @@
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+#include <stdbool.h>
+#include <stdint.h>
+
+// Returns false to stop the prediction.
+extern bool prompt_callback_bridge(uintptr_t h, char* word);
+extern void tokenizer_callback_bridge(uintptr_t h, char* word);
+extern void perplexity_callback_bridge(uintptr_t h, int chunk, int n_chunks, double ppl);
+extern bool beam_callback_bridge(uintptr_t h, int rank, int n_tokens, double score, bool eos, char* text);
+
+typedef struct llama_predict_result {
+    int prompt_tokens;
+    int completion_tokens;
+    // timings in microseconds
+    int64_t t_prompt_eval_us;
+    int64_t t_sample_us;
+    int64_t t_predict_us;
+    int n_predict;
+    int64_t t_first_token_us;
+    int64_t t_total_us;
+    // inference memory per token in bytes
+    int64_t mem_per_token;
+    // number of times the context was shifted
+    int n_context_shifts;
+} llama_predict_result;
+
+void *llama_allocate_state();
+void llama_free_state(void* state_ptr);
+
+int llama_bootstrap(const char *model_path, void* state_pr, int n_ctx, int n_parts, int memory_type_int);
+
+void* llama_allocate_params(const char *prompt, int seed, int threads, int tokens,
+                            int top_k, float top_p, float temp, float repeat_penalty,
+                            int repeat_last_n, int n_batch, bool ignore_eos,
+                            int mirostat, float mirostat_tau, float mirostat_eta,
+                            float min_p, float typical_p, float tfs_z,
+                            int n_samplers, const int* sampler_types, const float* sampler_values,
+                            float frequency_penalty, float presence_penalty, int n_penalty_exclude, const int* penalty_exclude,
+                            int num_beams, float length_penalty, bool early_stopping,
+                            const char *negative_prompt, float cfg_scale, bool instruct,
+                            bool context_shift, int n_keep);
+void llama_free_params(void* params_ptr);
+
+// Returns 0 at the token limit, 1 on errors, 2 at the end of text, 3 when
+// cancelled by cb and 5 if the negative prompt does not fit into the context.
+int llama_predict(void* params_ptr, void* state_pr, uintptr_t cb, llama_predict_result* result);
+
+// Beam search with params.num_beams beams. The words of the best beam are
+// sent to cb, the beams to beam_cb best first. beam_cb is called with a
+// NULL text after each step and returns false to stop the search. Returns
+// 4 if the prompt does not fit into the context, else like llama_predict.
+int llama_beam_search(void* params_ptr, void* state_pr, uintptr_t cb, uintptr_t beam_cb, llama_predict_result* result);
+
+char* llama_print_system_info(void);
+
+void llama_tokenize_prompt(void* state_ptr, const char* prompt, uintptr_t cb);
+
+int llama_embed(void* state_pr, const char* prompt, int threads, float* out, int n_out);
+
+int llama_n_embd(void* state_pr);
+
+int llama_perplexity(void* state_pr, const char* text, int threads, uintptr_t cb, double* ppl);
+
+#ifdef __cplusplus
+}
+#endif
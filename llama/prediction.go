@@ -0,0 +1,67 @@
+package llama
+
+import "context"
+
+// Prediction iterates over the words of a running prediction:
+//
+//	pred := model.PredictIter(ctx, params, "Hello")
+//	defer pred.Close()
+//	for pred.Next() {
+//		fmt.Print(pred.Word())
+//	}
+//	result, err := pred.Result()
+type Prediction struct {
+	words  chan string
+	word   string
+	cancel context.CancelFunc
+	result PredictResult
+	err    error
+}
+
+// PredictIter starts the prediction in the background. The prediction
+// waits for Next, so it runs at the speed of the reader.
+func (m *Model) PredictIter(ctx context.Context, params PredictParams, text string) *Prediction {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Prediction{
+		words:  make(chan string),
+		cancel: cancel,
+	}
+	go func() {
+		p.result, p.err = m.PredictContext(ctx, params, text, func(word string) {
+			select {
+			case p.words <- word:
+			case <-ctx.Done():
+			}
+		})
+		close(p.words)
+	}()
+	return p
+}
+
+// Next waits for the next word, it returns false when the prediction is
+// finished.
+func (p *Prediction) Next() bool {
+	word, ok := <-p.words
+	if !ok {
+		return false
+	}
+	p.word = word
+	return true
+}
+
+// Word returns the word read by Next.
+func (p *Prediction) Word() string {
+	return p.word
+}
+
+// Result returns the result of the prediction once Next returned false.
+func (p *Prediction) Result() (PredictResult, error) {
+	return p.result, p.err
+}
+
+// Close stops the prediction and waits until it returns.
+func (p *Prediction) Close() {
+	p.cancel()
+	for range p.words {
+	}
+}
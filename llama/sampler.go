@@ -0,0 +1,51 @@
+package llama
+
+import "fmt"
+
+// SamplerType is a stage of a sampler chain, the values match
+// llama_sampler_type in utils.h.
+type SamplerType int
+
+const (
+	SamplerRepeatPenalty SamplerType = iota + 1
+	SamplerTopK
+	SamplerTopP
+	SamplerMinP
+	SamplerTypical
+	SamplerTailFree
+	SamplerTemp
+)
+
+var samplerNames = map[SamplerType]string{
+	SamplerRepeatPenalty: "repeat_penalty",
+	SamplerTopK:          "top_k",
+	SamplerTopP:          "top_p",
+	SamplerMinP:          "min_p",
+	SamplerTypical:       "typical",
+	SamplerTailFree:      "tail_free",
+	SamplerTemp:          "temp",
+}
+
+func (t SamplerType) String() string {
+	if name, ok := samplerNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("SamplerType(%d)", int(t))
+}
+
+// ParseSamplerType returns the sampler of the given name, like top_k.
+func ParseSamplerType(name string) (SamplerType, error) {
+	for t, n := range samplerNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown sampler %q", name)
+}
+
+// SamplerStage is one step of a sampler chain. The value is the parameter
+// of the sampler, like K for top_k or the penalty for repeat_penalty.
+type SamplerStage struct {
+	Type  SamplerType
+	Value float32
+}
@@ -1,5 +1,6 @@
 #include "utils.h"
 
+#include <algorithm>
 #include <cassert>
 #include <cstring>
 #include <fstream>
@@ -449,78 +450,234 @@ void sample_top_k(std::vector<std::pair<double, llama_vocab::id>> & logits_id, i
     logits_id.resize(top_k);
 }
 
-llama_vocab::id llama_sample_top_p_top_k(
+void sample_repeat_penalty(std::vector<std::pair<double, llama_vocab::id>> & logits_id, const std::vector<llama_vocab::id> & last_n_tokens, double repeat_penalty) {
+    for (auto & kv : logits_id) {
+        if (std::find(last_n_tokens.begin(), last_n_tokens.end(), kv.second) != last_n_tokens.end()) {
+            kv.first = kv.first < 0.0 ? kv.first*repeat_penalty : kv.first/repeat_penalty;
+        }
+    }
+}
+
+void sample_frequency_presence(float * logits, int n_logits, const std::vector<llama_vocab::id> & last_n_tokens, double alpha_frequency, double alpha_presence) {
+    if (alpha_frequency == 0.0 && alpha_presence == 0.0) {
+        return;
+    }
+    std::unordered_map<llama_vocab::id, int> counts;
+    for (auto id : last_n_tokens) {
+        counts[id]++;
+    }
+    for (const auto & kv : counts) {
+        if (kv.first < 0 || kv.first >= n_logits) {
+            continue;
+        }
+        logits[kv.first] -= kv.second*alpha_frequency + (kv.second > 0 ? 1.0 : 0.0)*alpha_presence;
+    }
+}
+
+static void log_softmax(int n, const float * src, std::vector<float> & dst) {
+    const float max_l = *std::max_element(src, src + n);
+    double sum = 0.0;
+    for (int i = 0; i < n; i++) {
+        sum += exp(src[i] - max_l);
+    }
+    const float log_sum = max_l + log(sum);
+    dst.resize(n);
+    for (int i = 0; i < n; i++) {
+        dst[i] = src[i] - log_sum;
+    }
+}
+
+void sample_guidance(float * logits, const float * guidance_logits, int n_logits, float scale) {
+    std::vector<float> base;
+    std::vector<float> guidance;
+    log_softmax(n_logits, logits, base);
+    log_softmax(n_logits, guidance_logits, guidance);
+    for (int i = 0; i < n_logits; i++) {
+        logits[i] = guidance[i] + scale*(base[i] - guidance[i]);
+    }
+}
+
+void sample_temp(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double temp) {
+    const double scale = 1.0/temp;
+    for (auto & kv : logits_id) {
+        kv.first *= scale;
+    }
+}
+
+// scale the logits by the temperature and apply the repetition penalty
+static std::vector<std::pair<double, llama_vocab::id>> llama_penalized_logits(
         const llama_vocab & vocab,
         const float * logits,
         std::vector<llama_vocab::id> & last_n_tokens,
         double repeat_penalty,
-        int top_k,
-        double top_p,
-        double temp,
-        std::mt19937 & rng) {
+        double temp) {
     int n_logits = vocab.id_to_token.size();
 
     std::vector<std::pair<double, llama_vocab::id>> logits_id;
     logits_id.reserve(n_logits);
 
-    {
-        const double scale = 1.0/temp;
-        for (int i = 0; i < n_logits; ++i) {
-            // repetition penalty from CTRL paper (https://arxiv.org/abs/1909.05858)
-            // credit https://github.com/facebookresearch/llama/compare/main...shawwn:llama:main
-            if (std::find(last_n_tokens.begin(), last_n_tokens.end(), i) != last_n_tokens.end()) {
-                // if score < 0 then repetition penalty has to multiplied to reduce the previous token probability
-                if (logits[i] < 0.0) {
-                    logits_id.push_back(std::make_pair(logits[i]*scale*repeat_penalty, i));
-                } else {
-                    logits_id.push_back(std::make_pair(logits[i]*scale/repeat_penalty, i));
-                }
+    const double scale = 1.0/temp;
+    for (int i = 0; i < n_logits; ++i) {
+        // repetition penalty from CTRL paper (https://arxiv.org/abs/1909.05858)
+        // credit https://github.com/facebookresearch/llama/compare/main...shawwn:llama:main
+        if (std::find(last_n_tokens.begin(), last_n_tokens.end(), i) != last_n_tokens.end()) {
+            // if score < 0 then repetition penalty has to multiplied to reduce the previous token probability
+            if (logits[i] < 0.0) {
+                logits_id.push_back(std::make_pair(logits[i]*scale*repeat_penalty, i));
             } else {
-                logits_id.push_back(std::make_pair(logits[i]*scale, i));
+                logits_id.push_back(std::make_pair(logits[i]*scale/repeat_penalty, i));
             }
+        } else {
+            logits_id.push_back(std::make_pair(logits[i]*scale, i));
         }
     }
 
-    sample_top_k(logits_id, top_k);
+    return logits_id;
+}
 
-    double maxl = -INFINITY;
-    for (const auto & kv : logits_id) {
-        maxl = std::max(maxl, kv.first);
-    }
+// sort the logits descending and return their softmax probabilities
+static std::vector<double> llama_softmax_sorted(std::vector<std::pair<double, llama_vocab::id>> & logits_id) {
+    std::sort(logits_id.begin(), logits_id.end(),
+            [](const std::pair<double, llama_vocab::id> & a, const std::pair<double, llama_vocab::id> & b) {
+        return a.first > b.first;
+    });
 
-    // compute probs for the top K tokens
     std::vector<double> probs;
     probs.reserve(logits_id.size());
 
+    const double maxl = logits_id[0].first;
     double sum = 0.0;
     for (const auto & kv : logits_id) {
         double p = exp(kv.first - maxl);
         probs.push_back(p);
         sum += p;
     }
-
-    // normalize the probs
     for (auto & p : probs) {
         p /= sum;
     }
 
-    if (top_p < 1.0f) {
-        double cumsum = 0.0f;
-        for (int i = 0; i < (int) probs.size(); i++) {
-            cumsum += probs[i];
-            if (cumsum >= top_p) {
-                probs.resize(i + 1);
-                logits_id.resize(i + 1);
-                break;
-            }
+    return probs;
+}
+
+void sample_top_p(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double top_p) {
+    if (top_p >= 1.0 || logits_id.size() <= 1) {
+        return;
+    }
+    std::vector<double> probs = llama_softmax_sorted(logits_id);
+
+    double cumsum = 0.0;
+    for (int i = 0; i < (int) probs.size(); i++) {
+        cumsum += probs[i];
+        if (cumsum >= top_p) {
+            logits_id.resize(i + 1);
+            break;
         }
+    }
+}
+
+void sample_min_p(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double min_p) {
+    if (min_p <= 0.0 || logits_id.size() <= 1) {
+        return;
+    }
+    std::vector<double> probs = llama_softmax_sorted(logits_id);
 
-        cumsum = 1.0/cumsum;
-        for (int i = 0; i < (int) probs.size(); i++) {
-            probs[i] *= cumsum;
+    // keep the tokens with at least min_p times the probability of the most likely one
+    const double threshold = min_p * probs[0];
+    int n = 1;
+    while (n < (int) probs.size() && probs[n] >= threshold) {
+        ++n;
+    }
+    logits_id.resize(n);
+}
+
+void sample_tail_free(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double z) {
+    if (z <= 0.0 || z >= 1.0 || logits_id.size() <= 2) {
+        return;
+    }
+    std::vector<double> probs = llama_softmax_sorted(logits_id);
+
+    // absolute second derivatives of the sorted probabilities
+    std::vector<double> second_derivatives(probs.size() - 2);
+    double sum = 0.0;
+    for (int i = 0; i < (int) second_derivatives.size(); i++) {
+        const double d1 = probs[i] - probs[i + 1];
+        const double d2 = probs[i + 1] - probs[i + 2];
+        second_derivatives[i] = fabs(d1 - d2);
+        sum += second_derivatives[i];
+    }
+    if (sum <= 0.0) {
+        return;
+    }
+
+    // cut the tail where the normalized derivatives sum up to z
+    double cumsum = 0.0;
+    for (int i = 0; i < (int) second_derivatives.size(); i++) {
+        cumsum += second_derivatives[i] / sum;
+        if (cumsum > z) {
+            logits_id.resize(std::max(i, 1));
+            break;
+        }
+    }
+}
+
+void sample_typical(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double typical_p) {
+    if (typical_p <= 0.0 || typical_p >= 1.0 || logits_id.size() <= 1) {
+        return;
+    }
+    std::vector<double> probs = llama_softmax_sorted(logits_id);
+
+    double entropy = 0.0;
+    for (auto p : probs) {
+        if (p > 0.0) {
+            entropy -= p * log(p);
         }
     }
 
+    // order by how far the surprise of a token is from the entropy
+    std::vector<int> indices(probs.size());
+    std::vector<double> shifted(probs.size());
+    for (int i = 0; i < (int) probs.size(); i++) {
+        indices[i] = i;
+        shifted[i] = probs[i] > 0.0 ? fabs(-log(probs[i]) - entropy) : INFINITY;
+    }
+    std::stable_sort(indices.begin(), indices.end(), [&](int a, int b) {
+        return shifted[a] < shifted[b];
+    });
+
+    std::vector<std::pair<double, llama_vocab::id>> typical;
+    double cumsum = 0.0;
+    for (int i : indices) {
+        typical.push_back(logits_id[i]);
+        cumsum += probs[i];
+        if (cumsum >= typical_p) {
+            break;
+        }
+    }
+    logits_id = typical;
+}
+
+llama_vocab::id llama_sample_top_p_top_k(
+        const llama_vocab & vocab,
+        const float * logits,
+        std::vector<llama_vocab::id> & last_n_tokens,
+        double repeat_penalty,
+        int top_k,
+        double top_p,
+        double min_p,
+        double typical_p,
+        double tfs_z,
+        double temp,
+        std::mt19937 & rng) {
+    std::vector<std::pair<double, llama_vocab::id>> logits_id = llama_penalized_logits(vocab, logits, last_n_tokens, repeat_penalty, temp);
+
+    sample_top_k(logits_id, top_k);
+    sample_tail_free(logits_id, tfs_z);
+    sample_typical(logits_id, typical_p);
+    sample_top_p(logits_id, top_p);
+    sample_min_p(logits_id, min_p);
+
+    std::vector<double> probs = llama_softmax_sorted(logits_id);
+
     std::discrete_distribution<> dist(probs.begin(), probs.end());
     int idx = dist(rng);
 
@@ -646,3 +803,115 @@ size_t ggml_quantize_q4_1(float * src, void * dst, int n, int k, int qk, int64_t
 
     return (n/k)*row_size;
 }
+
+llama_vocab::id llama_sample_chain(
+        const llama_vocab & vocab,
+        const float * logits,
+        std::vector<llama_vocab::id> & last_n_tokens,
+        const std::vector<llama_sampler_stage> & samplers,
+        std::mt19937 & rng) {
+    std::vector<std::pair<double, llama_vocab::id>> logits_id = llama_penalized_logits(vocab, logits, last_n_tokens, 1.0, 1.0);
+
+    for (const auto & stage : samplers) {
+        switch (stage.type) {
+            case LLAMA_SAMPLER_REPEAT_PENALTY:
+                sample_repeat_penalty(logits_id, last_n_tokens, stage.value);
+                break;
+            case LLAMA_SAMPLER_TOP_K:
+                if ((int) stage.value > 0 && (int) stage.value < (int) logits_id.size()) {
+                    sample_top_k(logits_id, (int) stage.value);
+                }
+                break;
+            case LLAMA_SAMPLER_TOP_P:
+                sample_top_p(logits_id, stage.value);
+                break;
+            case LLAMA_SAMPLER_MIN_P:
+                sample_min_p(logits_id, stage.value);
+                break;
+            case LLAMA_SAMPLER_TYPICAL:
+                sample_typical(logits_id, stage.value);
+                break;
+            case LLAMA_SAMPLER_TAIL_FREE:
+                sample_tail_free(logits_id, stage.value);
+                break;
+            case LLAMA_SAMPLER_TEMP:
+                sample_temp(logits_id, stage.value);
+                break;
+        }
+    }
+
+    std::vector<double> probs = llama_softmax_sorted(logits_id);
+
+    std::discrete_distribution<> dist(probs.begin(), probs.end());
+    int idx = dist(rng);
+
+    return logits_id[idx].second;
+}
+
+llama_vocab::id llama_sample_mirostat(
+        const llama_vocab & vocab,
+        const float * logits,
+        std::vector<llama_vocab::id> & last_n_tokens,
+        double repeat_penalty,
+        double temp,
+        int version,
+        double tau,
+        double eta,
+        double & mu,
+        std::mt19937 & rng) {
+    std::vector<std::pair<double, llama_vocab::id>> logits_id = llama_penalized_logits(vocab, logits, last_n_tokens, repeat_penalty, temp);
+    std::vector<double> probs = llama_softmax_sorted(logits_id);
+
+    int k = probs.size();
+    if (version == 1) {
+        // estimate the Zipf exponent s from the top m tokens
+        const int m = std::min(100, (int) probs.size() - 1);
+        double sum_ti_bi = 0.0;
+        double sum_ti_sq = 0.0;
+        for (int i = 0; i < m; ++i) {
+            if (probs[i + 1] <= 0.0) {
+                break;
+            }
+            const double t_i = log((double) (i + 2) / (i + 1));
+            const double b_i = log(probs[i] / probs[i + 1]);
+            sum_ti_bi += t_i * b_i;
+            sum_ti_sq += t_i * t_i;
+        }
+        const double s_hat = sum_ti_sq > 0.0 ? sum_ti_bi / sum_ti_sq : 1.0;
+
+        // compute k from the estimated s and the target surprise mu
+        const double n = probs.size();
+        const double epsilon_hat = s_hat - 1;
+        const double k_hat = pow((epsilon_hat * pow(2, mu)) / (1 - pow(n, -epsilon_hat)), 1 / s_hat);
+        if (std::isfinite(k_hat)) {
+            k = std::max(1, std::min(k, (int) k_hat));
+        }
+    } else {
+        // keep the tokens with a surprise of at most mu
+        k = 0;
+        while (k < (int) probs.size() && -log2(probs[k]) <= mu) {
+            ++k;
+        }
+        k = std::max(k, 1);
+    }
+
+    probs.resize(k);
+    logits_id.resize(k);
+
+    double sum = 0.0;
+    for (auto p : probs) {
+        sum += p;
+    }
+    for (auto & p : probs) {
+        p /= sum;
+    }
+
+    std::discrete_distribution<> dist(probs.begin(), probs.end());
+    int idx = dist(rng);
+
+    // move mu towards the target surprise tau
+    const double surprise = -log2(probs[idx]);
+    mu -= eta * (surprise - tau);
+
+    return logits_id[idx].second;
+}
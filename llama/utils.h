@@ -0,0 +1,213 @@
+// Various helper functions and utilities
+
+#pragma once
+
+#include <string>
+#include <unordered_map>
+#include <vector>
+#include <random>
+#include <thread>
+
+//
+// CLI argument parsing
+//
+
+// stages of a sampler chain, the values are used by the Go bindings
+enum llama_sampler_type {
+    LLAMA_SAMPLER_REPEAT_PENALTY = 1,
+    LLAMA_SAMPLER_TOP_K          = 2,
+    LLAMA_SAMPLER_TOP_P          = 3,
+    LLAMA_SAMPLER_MIN_P          = 4,
+    LLAMA_SAMPLER_TYPICAL        = 5,
+    LLAMA_SAMPLER_TAIL_FREE      = 6,
+    LLAMA_SAMPLER_TEMP           = 7,
+};
+
+struct llama_sampler_stage {
+    llama_sampler_type type;
+    float value;
+};
+
+struct gpt_params {
+    int32_t seed          = -1;  // RNG seed
+    int32_t n_threads     = std::min(4, (int32_t) std::thread::hardware_concurrency());
+    int32_t n_predict     = 128; // new tokens to predict
+    int32_t repeat_last_n = 64;  // last n tokens to penalize
+    int32_t n_parts       = -1;  // amount of model parts (-1 = determine from model dimensions)
+    int32_t n_ctx         = 512; //context size
+
+    // sampling parameters
+    int32_t top_k = 40;
+    float   top_p = 0.95f;
+    float   temp  = 0.80f;
+    float   repeat_penalty  = 1.10f;
+    float   min_p     = 0.00f; // 0 = disabled
+    float   typical_p = 1.00f; // 1 = disabled
+    float   tfs_z     = 1.00f; // 1 = disabled
+
+    float   frequency_penalty = 0.00f; // 0 = disabled
+    float   presence_penalty  = 0.00f; // 0 = disabled
+    std::vector<int32_t> penalty_exclude; // token ids that are never penalized
+
+    // classifier-free guidance, the logits of negative_prompt are mixed in if cfg_scale is above 1
+    std::string negative_prompt = "";
+    float   cfg_scale = 1.00f; // 1 = disabled
+
+    // beam search, the sampling parameters are not used with more than one beam
+    int32_t num_beams      = 1;
+    float   length_penalty = 1.00f; // exponent of the length that the log probability is divided by
+    bool    early_stopping = false; // stop once num_beams beams are finished
+
+    // context shifting, once the context is full the first n_keep tokens and the
+    // most recent half of the others are kept and the generation continues
+    bool    context_shift = false;
+    int32_t n_keep        = 0; // 0 = the whole prompt, at most half the context
+
+    // sampler stages applied in order, empty uses llama_sample_top_p_top_k
+    std::vector<llama_sampler_stage> samplers;
+
+    int32_t mirostat     = 0;    // mirostat version, 0 = disabled
+    float   mirostat_tau = 5.00f; // target surprise
+    float   mirostat_eta = 0.10f; // learning rate
+
+    int32_t n_batch = 8; // batch size for prompt processing
+
+    std::string model  = "models/lamma-7B/ggml-model.bin"; // model path
+    std::string prompt = "";
+
+    std::vector<std::string> antiprompt; // string upon seeing which more user input is prompted
+
+    bool memory_f16        = false; // use f16 instead of f32 for memory kv
+    bool random_prompt     = false; // do not randomize prompt if none provided
+    bool use_color         = false; // use color to distinguish generations and inputs
+    bool interactive       = false; // interactive mode
+    bool interactive_start = false; // reverse prompt immediately
+    bool instruct          = false; // instruction mode (used for Alpaca models)
+    bool ignore_eos        = false; // do not stop generating after eos
+    bool perplexity        = false; // compute perplexity over the prompt
+};
+
+bool gpt_params_parse(int argc, char ** argv, gpt_params & params);
+
+void gpt_print_usage(int argc, char ** argv, const gpt_params & params);
+
+std::string gpt_random_prompt(std::mt19937 & rng);
+
+//
+// Model file parsing
+//
+
+#define FILE_MAGIC_UNVERSIONED 0x67676d6c // pre-versioned files
+#define FILE_MAGIC 0x67676d66 // 'ggmf' in hex
+#define FILE_VERSION 1
+
+//
+// Vocab utils
+//
+
+struct llama_vocab {
+    using id    = int32_t;
+    using token = std::string;
+
+    struct token_score {
+        token tok;
+        float score;
+    };
+
+    std::unordered_map<token, id> token_to_id;
+    std::vector<token_score> id_to_token;
+};
+
+void replace(std::string & str, const std::string & needle, const std::string & replacement);
+
+// poor-man's JSON parsing
+std::unordered_map<std::string, int32_t> json_parse(const std::string & fname);
+
+// TODO: temporary until #77 is merged, need this now for some tokenizer tests
+bool llama_vocab_load(const std::string & fname, llama_vocab & vocab);
+
+// TODO: this is probably wrong, but I cannot figure out how this tokenizer works ..
+// ref: https://github.com/google/sentencepiece
+std::vector<llama_vocab::id> llama_tokenize(const llama_vocab & vocab, const std::string & text, bool bos);
+
+// sample next token given probabilities for each embedding
+//
+//   - consider only the top K tokens
+//   - from them, drop the tail found by tail free sampling with parameter Z
+//   - from them, consider only the locally typical tokens with cumulative probability > typical P
+//   - from them, consider only the top tokens with cumulative probability > P
+//   - from them, consider only the tokens with at least min P times the top probability
+//
+llama_vocab::id llama_sample_top_p_top_k(
+        const llama_vocab & vocab,
+        const float * logits,
+        std::vector<llama_vocab::id> & last_n_tokens,
+        double repeat_penalty,
+        int top_k,
+        double top_p,
+        double min_p,
+        double typical_p,
+        double tfs_z,
+        double temp,
+        std::mt19937 & rng);
+
+// sample with the given stages applied in order to the logits
+llama_vocab::id llama_sample_chain(
+        const llama_vocab & vocab,
+        const float * logits,
+        std::vector<llama_vocab::id> & last_n_tokens,
+        const std::vector<llama_sampler_stage> & samplers,
+        std::mt19937 & rng);
+
+// Mirostat sampling (https://arxiv.org/abs/2007.14966)
+//   - version 1 estimates top K from the Zipf exponent of the distribution
+//   - version 2 drops the tokens with a surprise above mu
+//   - mu is updated to keep the surprise close to tau, start with 2*tau
+//
+llama_vocab::id llama_sample_mirostat(
+        const llama_vocab & vocab,
+        const float * logits,
+        std::vector<llama_vocab::id> & last_n_tokens,
+        double repeat_penalty,
+        double temp,
+        int version,
+        double tau,
+        double eta,
+        double & mu,
+        std::mt19937 & rng);
+
+// filer to top K tokens from list of logits
+void sample_top_k(std::vector<std::pair<double, llama_vocab::id>> & logits_id, int top_k);
+
+// penalize the logits of the last n tokens, see llama_sample_top_p_top_k
+void sample_repeat_penalty(std::vector<std::pair<double, llama_vocab::id>> & logits_id, const std::vector<llama_vocab::id> & last_n_tokens, double repeat_penalty);
+
+// subtract the frequency penalty times the count of each of the last n tokens
+// and the presence penalty once from the logits, like the OpenAI API
+void sample_frequency_presence(float * logits, int n_logits, const std::vector<llama_vocab::id> & last_n_tokens, double alpha_frequency, double alpha_presence);
+
+// classifier-free guidance, mix the log softmax of the logits with the ones
+// of the negative prompt: guidance + scale*(logits - guidance)
+void sample_guidance(float * logits, const float * guidance_logits, int n_logits, float scale);
+
+// divide the logits by the temperature
+void sample_temp(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double temp);
+
+// filter to the top tokens with cumulative probability > top_p, 1 disables it
+void sample_top_p(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double top_p);
+
+// filter to the tokens with at least min_p times the top probability, 0 disables it
+void sample_min_p(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double min_p);
+
+// tail free sampling (https://www.trentonbricken.com/Tail-Free-Sampling/), 0 or 1 disables it
+void sample_tail_free(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double z);
+
+// locally typical sampling (https://arxiv.org/abs/2202.00666), 0 or 1 disables it
+void sample_typical(std::vector<std::pair<double, llama_vocab::id>> & logits_id, double typical_p);
+
+//
+// Quantization
+//
+
+size_t ggml_quantize_q4_0(float * src, void * dst, int n, int k, int qk, int64_t * hist);
+size_t ggml_quantize_q4_1(float * src, void * dst, int n, int k, int qk, int64_t * hist);
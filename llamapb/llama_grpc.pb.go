@@ -0,0 +1,248 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.23.4
+// source: llama.proto
+
+package llamapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Llama_Complete_FullMethodName   = "/llama.Llama/Complete"
+	Llama_Tokenize_FullMethodName   = "/llama.Llama/Tokenize"
+	Llama_Embed_FullMethodName      = "/llama.Llama/Embed"
+	Llama_ListModels_FullMethodName = "/llama.Llama/ListModels"
+)
+
+// LlamaClient is the client API for Llama service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LlamaClient interface {
+	Complete(ctx context.Context, in *CompleteRequest, opts ...grpc.CallOption) (Llama_CompleteClient, error)
+	Tokenize(ctx context.Context, in *TokenizeRequest, opts ...grpc.CallOption) (*TokenizeResponse, error)
+	Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error)
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+}
+
+type llamaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLlamaClient(cc grpc.ClientConnInterface) LlamaClient {
+	return &llamaClient{cc}
+}
+
+func (c *llamaClient) Complete(ctx context.Context, in *CompleteRequest, opts ...grpc.CallOption) (Llama_CompleteClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Llama_ServiceDesc.Streams[0], Llama_Complete_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &llamaCompleteClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Llama_CompleteClient interface {
+	Recv() (*CompleteResponse, error)
+	grpc.ClientStream
+}
+
+type llamaCompleteClient struct {
+	grpc.ClientStream
+}
+
+func (x *llamaCompleteClient) Recv() (*CompleteResponse, error) {
+	m := new(CompleteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *llamaClient) Tokenize(ctx context.Context, in *TokenizeRequest, opts ...grpc.CallOption) (*TokenizeResponse, error) {
+	out := new(TokenizeResponse)
+	err := c.cc.Invoke(ctx, Llama_Tokenize_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *llamaClient) Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error) {
+	out := new(EmbedResponse)
+	err := c.cc.Invoke(ctx, Llama_Embed_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *llamaClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, Llama_ListModels_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LlamaServer is the server API for Llama service.
+// All implementations must embed UnimplementedLlamaServer
+// for forward compatibility
+type LlamaServer interface {
+	Complete(*CompleteRequest, Llama_CompleteServer) error
+	Tokenize(context.Context, *TokenizeRequest) (*TokenizeResponse, error)
+	Embed(context.Context, *EmbedRequest) (*EmbedResponse, error)
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	mustEmbedUnimplementedLlamaServer()
+}
+
+// UnimplementedLlamaServer must be embedded to have forward compatible implementations.
+type UnimplementedLlamaServer struct {
+}
+
+func (UnimplementedLlamaServer) Complete(*CompleteRequest, Llama_CompleteServer) error {
+	return status.Errorf(codes.Unimplemented, "method Complete not implemented")
+}
+func (UnimplementedLlamaServer) Tokenize(context.Context, *TokenizeRequest) (*TokenizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tokenize not implemented")
+}
+func (UnimplementedLlamaServer) Embed(context.Context, *EmbedRequest) (*EmbedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Embed not implemented")
+}
+func (UnimplementedLlamaServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
+func (UnimplementedLlamaServer) mustEmbedUnimplementedLlamaServer() {}
+
+// UnsafeLlamaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LlamaServer will
+// result in compilation errors.
+type UnsafeLlamaServer interface {
+	mustEmbedUnimplementedLlamaServer()
+}
+
+func RegisterLlamaServer(s grpc.ServiceRegistrar, srv LlamaServer) {
+	s.RegisterService(&Llama_ServiceDesc, srv)
+}
+
+func _Llama_Complete_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CompleteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LlamaServer).Complete(m, &llamaCompleteServer{stream})
+}
+
+type Llama_CompleteServer interface {
+	Send(*CompleteResponse) error
+	grpc.ServerStream
+}
+
+type llamaCompleteServer struct {
+	grpc.ServerStream
+}
+
+func (x *llamaCompleteServer) Send(m *CompleteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Llama_Tokenize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LlamaServer).Tokenize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Llama_Tokenize_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LlamaServer).Tokenize(ctx, req.(*TokenizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Llama_Embed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmbedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LlamaServer).Embed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Llama_Embed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LlamaServer).Embed(ctx, req.(*EmbedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Llama_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LlamaServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Llama_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LlamaServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Llama_ServiceDesc is the grpc.ServiceDesc for Llama service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Llama_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "llama.Llama",
+	HandlerType: (*LlamaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Tokenize",
+			Handler:    _Llama_Tokenize_Handler,
+		},
+		{
+			MethodName: "Embed",
+			Handler:    _Llama_Embed_Handler,
+		},
+		{
+			MethodName: "ListModels",
+			Handler:    _Llama_ListModels_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Complete",
+			Handler:       _Llama_Complete_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "llama.proto",
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/cornelk/llama-go/llama"
 )
 
 func getExecutePath() string {
@@ -24,62 +33,204 @@ func getExecutePath() string {
 
 func main() {
 	var (
-		modelPath  string
-		listenAddr string
-		threads    int
-		seed       int
-		nctx       int
 		mode       string
 		sockFile   string
-		workers    int
-		debug      bool
-		nparts     int
+		configFile string
+		textFile   string
+		prompt     string
+		reverse    string
+		tokens     int
+		history    string
+		format     string
+		output     string
+		bench      benchFlags
 	)
+	cfg := DefaultConfig()
 	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	flags.StringVar(&modelPath, "m", "", "path to q4_0.bin model file to load")
-	flags.StringVar(&listenAddr, "l", "127.0.0.1:4000", "Listen address")
-	flags.StringVar(&mode, "M", "master", "process mode (master|worker)")
+	flags.StringVar(&configFile, "config", "", "YAML or TOML config file, flags override values of the file")
+	flags.StringVar(&cfg.Model.Path, "m", cfg.Model.Path, "path to q4_0.bin model file to load")
+	flags.StringVar(&cfg.Model.Backend, "backend", cfg.Model.Backend, "backend of the workers (llama|fake), fake generates scripted tokens from the YAML file -m for tests")
+	flags.StringVar(&cfg.Server.Listen, "l", cfg.Server.Listen, "Listen address")
+	flags.StringVar(&cfg.Server.GRPCListen, "g", cfg.Server.GRPCListen, "gRPC listen address (disabled if empty)")
+	flags.StringVar(&mode, "M", "master", "process mode (master|worker|bench|perplexity|chat|complete|batch), can also be given as first argument")
 	flags.StringVar(&sockFile, "S", "", "worker listen socket file")
-	flags.IntVar(&threads, "t", 4, "Number of threads to use during computation")
-	flags.IntVar(&seed, "s", -1, "seed")
-	flags.IntVar(&nctx, "c", 2048, "context size")
-	flags.IntVar(&workers, "w", 2, "Number workers")
-	flags.IntVar(&nparts, "n", -1, "Number model part files")
-	flags.BoolVar(&debug, "d", false, "Debug enabler")
+	flags.StringVar(&textFile, "f", "", "text file to evaluate in perplexity mode, prompt file in chat and complete mode (- for stdin), input JSONL file in batch mode")
+	flags.StringVar(&prompt, "p", "", "prompt in chat and complete mode")
+	flags.StringVar(&reverse, "r", "User:", "reverse prompt, chat mode waits for input when the model writes it")
+	flags.IntVar(&tokens, "tokens", 256, "max number of tokens to generate per reply in chat mode or per completion in complete mode")
+	flags.StringVar(&format, "format", "text", "output format of complete mode (text|json)")
+	flags.StringVar(&output, "o", "", "output JSONL file of batch mode, also the checkpoint to resume from")
+	flags.StringVar(&history, "chat-history", "", "file to keep the chat conversation across runs")
+	flags.Var(intPtrFlag{&cfg.Defaults.TopK}, "top-k", "default top_k sampling parameter")
+	flags.Var(float32PtrFlag{&cfg.Defaults.TopP}, "top-p", "default top_p sampling parameter")
+	flags.Var(float32PtrFlag{&cfg.Defaults.Temp}, "temp", "default temperature")
+	flags.Var(float32PtrFlag{&cfg.Defaults.RepeatPenalty}, "repeat-penalty", "default repeat penalty")
+	flags.Var(intPtrFlag{&cfg.Defaults.RepeatLastN}, "repeat-last-n", "default number of last tokens to penalize")
+	flags.Var(intPtrFlag{&cfg.Defaults.Mirostat}, "mirostat", "default Mirostat sampling version, 0 disables it")
+	flags.Var(float32PtrFlag{&cfg.Defaults.MirostatTau}, "mirostat-tau", "default Mirostat target surprise")
+	flags.Var(float32PtrFlag{&cfg.Defaults.MirostatEta}, "mirostat-eta", "default Mirostat learning rate")
+	flags.Var(float32PtrFlag{&cfg.Defaults.MinP}, "min-p", "default min_p sampling parameter, 0 disables it")
+	flags.Var(float32PtrFlag{&cfg.Defaults.TypicalP}, "typical-p", "default locally typical sampling parameter, 0 disables it")
+	flags.Var(float32PtrFlag{&cfg.Defaults.TfsZ}, "tfs-z", "default tail free sampling parameter, 0 disables it")
+	flags.Var(float32PtrFlag{&cfg.Defaults.FrequencyPenalty}, "frequency-penalty", "default frequency penalty")
+	flags.Var(float32PtrFlag{&cfg.Defaults.PresencePenalty}, "presence-penalty", "default presence penalty")
+	flags.Var(stringListFlag{&cfg.Defaults.Samplers}, "samplers", "default sampler chain, comma separated like top_k=20,typical,temp")
+	flags.Var(boolPtrFlag{&cfg.Defaults.Instruct}, "instruct", "wrap prompts in the instruction and response markers of Alpaca models, not used in chat mode")
+	flags.Var(boolPtrFlag{&cfg.Defaults.ContextShift}, "context-shift", "keep generating once the context is full, the start of the prompt and the most recent half of the context are kept")
+	flags.Var(intPtrFlag{&cfg.Defaults.ContextKeep}, "context-keep", "tokens at the start of the prompt kept by -context-shift (default the whole prompt)")
+	flags.IntVar(&cfg.Model.Threads, "t", cfg.Model.Threads, "Number of threads to use during computation")
+	flags.IntVar(&cfg.Model.Seed, "s", cfg.Model.Seed, "seed")
+	flags.IntVar(&cfg.Model.CtxSize, "c", cfg.Model.CtxSize, "context size")
+	flags.IntVar(&cfg.Model.Workers, "w", cfg.Model.Workers, "Number workers")
+	flags.IntVar(&cfg.Model.Parts, "n", cfg.Model.Parts, "Number model part files")
+	flags.BoolVar(&cfg.Server.Debug, "d", cfg.Server.Debug, "Debug enabler")
+	flags.DurationVar((*time.Duration)(&cfg.Server.Grace), "grace", time.Duration(cfg.Server.Grace), "Grace period to finish running jobs on shutdown")
+	flags.StringVar(&cfg.Server.TLS.Cert, "tls-cert", cfg.Server.TLS.Cert, "TLS certificate file, reloaded on SIGHUP")
+	flags.StringVar(&cfg.Server.TLS.Key, "tls-key", cfg.Server.TLS.Key, "TLS private key file, reloaded on SIGHUP")
+	flags.StringVar(&cfg.Server.TLS.ClientCA, "tls-client-ca", cfg.Server.TLS.ClientCA, "CA file to verify client certificates (enables mTLS)")
+	flags.StringVar(&bench.prompt, "bench-prompt", "32,128,512", "bench: comma separated prompt lengths in tokens")
+	flags.StringVar(&bench.gen, "bench-gen", "32,128", "bench: comma separated generation lengths in tokens")
+	flags.StringVar(&bench.threads, "bench-threads", "", "bench: comma separated thread counts (default -t)")
+	flags.StringVar(&bench.batch, "bench-batch", "8", "bench: comma separated batch sizes for prompt evaluation")
+	flags.IntVar(&bench.repeat, "bench-repeat", 1, "bench: runs of each combination, results are averaged")
+	flags.BoolVar(&bench.json, "bench-json", false, "bench: print results as JSON lines")
 
-	err := flags.Parse(os.Args[1:])
+	// The mode can be given as subcommand: llama-go complete -p ...
+	args := os.Args[1:]
+	subcommand := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand = args[0]
+		args = args[1:]
+	}
+	err := flags.Parse(args)
 	if err != nil {
 		panic(err)
 	}
+	if subcommand != "" {
+		mode = subcommand
+	}
+
+	if configFile != "" {
+		// Remember the flags given on command line and set them again
+		// after loading the file, so that flags win over the file.
+		setFlags := map[string]string{}
+		flags.Visit(func(f *flag.Flag) {
+			setFlags[f.Name] = f.Value.String()
+		})
+		err = cfg.LoadFile(configFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for name, value := range setFlags {
+			flags.Set(name, value)
+		}
+	}
+
+	// Workers get their settings from the master
+	if mode != "worker" {
+		err = cfg.Validate()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
 
 	execFile, err := os.Executable()
 	if err != nil {
 		panic(err)
 	}
 
-	staticPath := getExecutePath() + "/static"
-
-	if modelPath == "" {
-		fmt.Println("Require model path")
-		return
+	if cfg.Server.StaticPath == "" {
+		cfg.Server.StaticPath = getExecutePath() + "/static"
 	}
 
 	switch mode {
 	case "worker":
-		runWorkerMode(sockFile, modelPath, threads, seed, nctx, nparts)
+		if cfg.Model.Path == "" {
+			fmt.Println("Require model path")
+			return
+		}
+		runWorkerMode(sockFile, cfg.Model.Backend, cfg.Model.Path, cfg.Model.Threads, cfg.Model.CtxSize, cfg.Model.Parts)
+	case "bench":
+		if cfg.Model.Path == "" {
+			fmt.Println("Require model path")
+			os.Exit(1)
+		}
+		opts, err := bench.Options(cfg.Model.Threads)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		runBenchMode(cfg, opts)
+	case "perplexity":
+		if cfg.Model.Path == "" || textFile == "" {
+			fmt.Println("Require model path and text file")
+			os.Exit(1)
+		}
+		runPerplexityMode(cfg, textFile)
+	case "chat":
+		if cfg.Model.Path == "" {
+			fmt.Println("Require model path")
+			os.Exit(1)
+		}
+		if textFile != "" {
+			data, err := os.ReadFile(textFile)
+			if err != nil {
+				fmt.Println("Cannot read prompt file:", err)
+				os.Exit(1)
+			}
+			prompt = string(data)
+		}
+		runChatMode(cfg, prompt, reverse, tokens, history)
+	case "complete":
+		if cfg.Model.Path == "" {
+			fmt.Fprintln(os.Stderr, "Require model path")
+			os.Exit(1)
+		}
+		prompt, err = readPrompt(prompt, textFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if prompt == "" {
+			fmt.Fprintln(os.Stderr, "Empty prompt")
+			os.Exit(1)
+		}
+		runCompleteMode(cfg, prompt, tokens, format)
+	case "batch":
+		if cfg.Model.Path == "" || textFile == "" || output == "" {
+			fmt.Fprintln(os.Stderr, "Require model path, input file and output file")
+			os.Exit(1)
+		}
+		runBatchMode(execFile, cfg, textFile, output, tokens)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown mode %q\n", mode)
+		os.Exit(2)
 	case "master":
-		runMasterMode(execFile, listenAddr, staticPath, workers, modelPath, threads, seed, nctx, nparts, debug)
+		runMasterMode(execFile, cfg)
 	}
 }
 
-func runWorkerMode(sockFile string, modelPath string, threads int, seed int, nctx int, nparts int) {
-	model := NewGGMLModel(modelPath, nctx, threads, nparts)
-	err := model.Load()
+func runWorkerMode(sockFile string, backendName string, modelPath string, threads int, nctx int, nparts int) {
+	backend, err := NewBackend(backendName, modelPath, nctx, threads, nparts)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	err = backend.Load()
 	if err != nil {
 		log.Println("Cannot Load Model:", err)
 		os.Exit(1)
 	}
-	worker := NewWorker(model, sockFile)
+	worker := NewWorker(backend, sockFile)
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		<-sigCh
+		worker.Close()
+		os.Exit(0)
+	}()
 	err = worker.Run()
 	if err != nil {
 		log.Println("Cannot Run worker:", err)
@@ -88,18 +239,377 @@ func runWorkerMode(sockFile string, modelPath string, threads int, seed int, nct
 	}
 }
 
-func runMasterMode(execFile string, listenAddr string, staticPath string, workers int, modelPath string, threads int, seed int, nctx int, nparts int, debug bool) {
-	wm := NewWorkerManager(execFile, modelPath, workers, nctx, nparts, threads, debug)
-	wm.StartWorkers()
+// intPtrFlag and float32PtrFlag set optional config values, nil means the
+// flag was not given.
+type intPtrFlag struct {
+	p **int
+}
+
+func (f intPtrFlag) String() string {
+	if f.p == nil || *f.p == nil {
+		return ""
+	}
+	return strconv.Itoa(**f.p)
+}
+
+func (f intPtrFlag) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*f.p = &v
+	return nil
+}
+
+type float32PtrFlag struct {
+	p **float32
+}
+
+func (f float32PtrFlag) String() string {
+	if f.p == nil || *f.p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(**f.p), 'g', -1, 32)
+}
+
+func (f float32PtrFlag) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return err
+	}
+	fv := float32(v)
+	*f.p = &fv
+	return nil
+}
+
+type boolPtrFlag struct {
+	p **bool
+}
+
+func (f boolPtrFlag) String() string {
+	if f.p == nil || *f.p == nil {
+		return ""
+	}
+	return strconv.FormatBool(**f.p)
+}
+
+func (f boolPtrFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*f.p = &v
+	return nil
+}
+
+func (f boolPtrFlag) IsBoolFlag() bool {
+	return true
+}
+
+// stringListFlag sets a config list from a comma separated value.
+type stringListFlag struct {
+	p *[]string
+}
+
+func (f stringListFlag) String() string {
+	if f.p == nil {
+		return ""
+	}
+	return strings.Join(*f.p, ",")
+}
+
+func (f stringListFlag) Set(s string) error {
+	*f.p = nil
+	if s != "" {
+		*f.p = strings.Split(s, ",")
+	}
+	return nil
+}
+
+type benchFlags struct {
+	prompt  string
+	gen     string
+	threads string
+	batch   string
+	repeat  int
+	json    bool
+}
+
+func (f benchFlags) Options(threads int) (BenchOptions, error) {
+	var err error
+	opts := BenchOptions{
+		Threads: []int{threads},
+		Repeat:  f.repeat,
+		JSON:    f.json,
+	}
+	if opts.PromptLengths, err = parseIntList(f.prompt); err != nil {
+		return opts, fmt.Errorf("Invalid -bench-prompt: %w", err)
+	}
+	if opts.GenLengths, err = parseIntList(f.gen); err != nil {
+		return opts, fmt.Errorf("Invalid -bench-gen: %w", err)
+	}
+	if opts.BatchSizes, err = parseIntList(f.batch); err != nil {
+		return opts, fmt.Errorf("Invalid -bench-batch: %w", err)
+	}
+	if f.threads != "" {
+		if opts.Threads, err = parseIntList(f.threads); err != nil {
+			return opts, fmt.Errorf("Invalid -bench-threads: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+func runBenchMode(cfg *Config, opts BenchOptions) {
+	model := llama.NewModel(cfg.Model.Path, cfg.Model.CtxSize, cfg.Model.Threads, cfg.Model.Parts)
+	err := model.Load()
+	if err != nil {
+		log.Println("Cannot Load Model:", err)
+		os.Exit(1)
+	}
+	if !opts.JSON {
+		fmt.Println(llama.SystemInfo())
+	}
+	err = RunBench(model, cfg.Model.CtxSize, opts, os.Stdout)
+	if err != nil {
+		log.Println("Bench got error:", err)
+		os.Exit(1)
+	}
+}
+
+func runPerplexityMode(cfg *Config, textFile string) {
+	text, err := os.ReadFile(textFile)
+	if err != nil {
+		log.Println("Cannot read text file:", err)
+		os.Exit(1)
+	}
+	model := llama.NewModel(cfg.Model.Path, cfg.Model.CtxSize, cfg.Model.Threads, cfg.Model.Parts)
+	err = model.Load()
+	if err != nil {
+		log.Println("Cannot Load Model:", err)
+		os.Exit(1)
+	}
+	start := time.Now()
+	ppl, err := model.Perplexity(string(text), func(chunk llama.PerplexityChunk) {
+		elapsed := time.Since(start)
+		eta := elapsed / time.Duration(chunk.Chunk) * time.Duration(chunk.Chunks-chunk.Chunk)
+		fmt.Printf("[%d/%d] %.4f (ETA %v)\n", chunk.Chunk, chunk.Chunks, chunk.Perplexity, eta.Round(time.Second))
+	})
+	if err != nil {
+		log.Println("Perplexity got error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("perplexity: %.4f\n", ppl)
+}
+
+func runChatMode(cfg *Config, prompt string, reverse string, tokens int, history string) {
+	params := CompletionParams{
+		Tokens:        tokens,
+		TopK:          40,
+		TopP:          0.95,
+		Temp:          0.8,
+		RepeatPenalty: 1.3,
+		RepeatLastN:   64,
+		MirostatTau:   llama.DefaultMirostatTau,
+		MirostatEta:   llama.DefaultMirostatEta,
+	}
+	cfg.Defaults.Apply(&params)
+	// the transcript has its own turn markers
+	params.Instruct = false
+	if _, err := params.samplerChain(); err != nil {
+		log.Println("Invalid sampler chain:", err)
+		os.Exit(1)
+	}
+	model := llama.NewModel(cfg.Model.Path, cfg.Model.CtxSize, cfg.Model.Threads, cfg.Model.Parts)
+	err := model.Load()
+	if err != nil {
+		log.Println("Cannot Load Model:", err)
+		os.Exit(1)
+	}
+	chat := NewChat(model, prompt, reverse, params, cfg.Model.Seed)
+	chat.HistoryFile = history
+	chat.Color = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	err = chat.Run()
+	if err != nil {
+		log.Println("Chat got error:", err)
+		os.Exit(1)
+	}
+}
 
-	info := SystemInfo()
+func runCompleteMode(cfg *Config, prompt string, tokens int, format string) {
+	model := llama.NewModel(cfg.Model.Path, cfg.Model.CtxSize, cfg.Model.Threads, cfg.Model.Parts)
+	err := model.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot Load Model:", err)
+		os.Exit(1)
+	}
+	params := &CompletionParams{
+		Prompt:        prompt,
+		Tokens:        tokens,
+		TopK:          40,
+		TopP:          0.95,
+		Temp:          0.8,
+		RepeatPenalty: 1.3,
+		RepeatLastN:   64,
+	}
+	cfg.Defaults.Apply(params)
+	err = RunComplete(model, params, cfg.Model.Seed, format, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if format == "text" && isTerminal(os.Stdout) {
+		fmt.Println()
+	}
+}
+
+func runBatchMode(execFile string, cfg *Config, input string, output string, tokens int) {
+	batch := &Batch{
+		Input:       input,
+		Output:      output,
+		Concurrency: cfg.Model.Workers,
+		Tokens:      tokens,
+		Defaults:    cfg.Defaults,
+		Limits:      cfg.Limits,
+	}
+	var wm *WorkerManager
+	if cfg.Model.Workers <= 1 {
+		backend, err := NewBackend(cfg.Model.Backend, cfg.Model.Path, cfg.Model.CtxSize, cfg.Model.Threads, cfg.Model.Parts)
+		if err == nil {
+			err = backend.Load()
+		}
+		if err != nil {
+			log.Println("Cannot Load Model:", err)
+			os.Exit(1)
+		}
+		batch.Run = ModelBatchRun(backend, cfg.Model.Seed)
+	} else {
+		wm = NewWorkerManager("batch", execFile, cfg.Model.Path, cfg.Model.Backend, cfg.Model.Workers, cfg.Model.CtxSize, cfg.Model.Parts, cfg.Model.Threads, cfg.Server.Debug)
+		wm.StartWorkers()
+		err := wm.WaitReady()
+		if err != nil {
+			log.Println("Cannot start workers:", err)
+			wm.stopWorkers()
+			os.Exit(1)
+		}
+		batch.Run = WorkerBatchRun(wm, cfg.Model.Seed)
+	}
+
+	// The first signal finishes the running requests, the second exits
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigCh
+		log.Println("Stopping, waiting for running requests. Run again with the same output file to resume")
+		cancel()
+		<-sigCh
+		if wm != nil {
+			wm.stopWorkers()
+		}
+		os.Exit(1)
+	}()
+
+	err := batch.Process(ctx)
+	if wm != nil {
+		wm.Shutdown(context.Background())
+	}
+	if err != nil {
+		log.Println("Batch got error:", err)
+		os.Exit(1)
+	}
+}
+
+func runMasterMode(execFile string, cfg *Config) {
+	var tlsCfg *tls.Config
+	if tlsOpts := cfg.TLSOptions(); tlsOpts.Enabled() {
+		var err error
+		tlsCfg, err = NewTLSConfig(tlsOpts)
+		if err != nil {
+			log.Println("Cannot setup TLS:", err)
+			os.Exit(1)
+		}
+	}
+
+	pools := NewWorkerPools(cfg.DefaultPoolName())
+	for _, pc := range cfg.PoolConfigs() {
+		wm := NewWorkerManager(pc.Name, execFile, pc.Path, pc.Backend, pc.Workers, pc.CtxSize, pc.Parts, pc.Threads, cfg.Server.Debug)
+		pools.Add(pc.Name, wm)
+	}
+	pools.StartWorkers()
+
+	info := llama.SystemInfo()
 	fmt.Println(info)
 
-	srv := APIServer{
-		Seed:       seed,
-		WorkerMgr:  wm,
-		Listen:     listenAddr,
-		StaticPath: staticPath,
+	var gsrv *GRPCServer
+	if cfg.Server.GRPCListen != "" {
+		gsrv = &GRPCServer{
+			Seed:     cfg.Model.Seed,
+			Pools:    pools,
+			Listen:   cfg.Server.GRPCListen,
+			TLS:      tlsCfg,
+			Defaults: cfg.Defaults,
+			Limits:   cfg.Limits,
+			APIKeys:  cfg.Auth.APIKeys,
+		}
+		go func() {
+			err := gsrv.Run()
+			if err != nil {
+				log.Println("Cannot run gRPC server:", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	srv := &APIServer{
+		Seed:       cfg.Model.Seed,
+		Pools:      pools,
+		Listen:     cfg.Server.Listen,
+		StaticPath: cfg.Server.StaticPath,
+		TLS:        tlsCfg,
+		Defaults:   cfg.Defaults,
+		Limits:     cfg.Limits,
+		APIKeys:    cfg.Auth.APIKeys,
+		AdminKeys:  cfg.Auth.AdminKeys,
+	}
+	go func() {
+		err := srv.Run()
+		if err != nil {
+			log.Println("Cannot run API server:", err)
+			os.Exit(1)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-sigCh
+	grace := time.Duration(cfg.Server.Grace)
+	log.Printf("Got signal %v, shutting down with grace period %v", sig, grace)
+	// A second signal skips the grace period
+	go func() {
+		<-sigCh
+		log.Println("Force shutdown")
+		pools.stopWorkers()
+		os.Exit(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		srv.Shutdown(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		if gsrv != nil {
+			gsrv.Shutdown(ctx)
+		}
+	}()
+	err := pools.Shutdown(ctx)
+	if err != nil {
+		log.Println("Shutdown got error:", err)
 	}
-	srv.Run()
+	wg.Wait()
+	log.Println("Shutdown complete")
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const DefaultPoolName = "default"
+
+// WorkerPools holds the worker managers of all served models, requests are
+// routed to a pool by model name.
+type WorkerPools struct {
+	pools       map[string]*WorkerManager
+	names       []string
+	defaultName string
+}
+
+func NewWorkerPools(defaultName string) *WorkerPools {
+	return &WorkerPools{
+		pools:       map[string]*WorkerManager{},
+		defaultName: defaultName,
+	}
+}
+
+func (p *WorkerPools) Add(name string, wm *WorkerManager) {
+	p.pools[name] = wm
+	p.names = append(p.names, name)
+}
+
+// Get returns the pool of the model, empty name means the default pool.
+func (p *WorkerPools) Get(name string) (*WorkerManager, error) {
+	if name == "" {
+		name = p.defaultName
+	}
+	wm, have := p.pools[name]
+	if !have {
+		return nil, fmt.Errorf("Unknown model %q", name)
+	}
+	return wm, nil
+}
+
+func (p *WorkerPools) DefaultName() string {
+	return p.defaultName
+}
+
+func (p *WorkerPools) StartWorkers() {
+	for _, name := range p.names {
+		p.pools[name].StartWorkers()
+	}
+}
+
+func (p *WorkerPools) ModelInfos() []ModelInfo {
+	ret := make([]ModelInfo, 0, len(p.names))
+	for _, name := range p.names {
+		ret = append(ret, p.pools[name].ModelInfo())
+	}
+	return ret
+}
+
+// Shutdown drains and stops all pools in parallel.
+func (p *WorkerPools) Shutdown(ctx context.Context) error {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		ret  error
+	)
+	for _, name := range p.names {
+		wg.Add(1)
+		go func(wm *WorkerManager) {
+			defer wg.Done()
+			err := wm.Shutdown(ctx)
+			if err != nil {
+				lock.Lock()
+				ret = err
+				lock.Unlock()
+			}
+		}(p.pools[name])
+	}
+	wg.Wait()
+	return ret
+}
+
+func (p *WorkerPools) stopWorkers() {
+	for _, name := range p.names {
+		p.pools[name].stopWorkers()
+	}
+}
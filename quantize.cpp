@@ -1,3 +1,5 @@
+//go:build ignore
+
 #include "ggml.h"
 
 #include "utils.h"
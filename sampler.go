@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+// maxSamplers limits the number of stages of a sampler chain.
+const maxSamplers = 16
+
+// parseSamplerStage parses a stage of a sampler chain like top_k or
+// top_k=20. The value is nil if the stage has none.
+func parseSamplerStage(s string) (llama.SamplerType, *float32, error) {
+	name, value, hasValue := strings.Cut(strings.TrimSpace(s), "=")
+	t, err := llama.ParseSamplerType(strings.TrimSpace(name))
+	if err != nil {
+		return 0, nil, err
+	}
+	if !hasValue {
+		return t, nil, nil
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 32)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid value of sampler %s: %w", name, err)
+	}
+	fv := float32(v)
+	if err := checkSamplerValue(t, fv); err != nil {
+		return 0, nil, err
+	}
+	return t, &fv, nil
+}
+
+func checkSamplerValue(t llama.SamplerType, v float32) error {
+	switch t {
+	case llama.SamplerTopK:
+		if v < 1 || v != float32(int(v)) {
+			return fmt.Errorf("Sampler top_k must be a positive integer, got %v", v)
+		}
+	case llama.SamplerTopP:
+		if v <= 0 || v > 1 {
+			return fmt.Errorf("Sampler top_p must be in (0, 1], got %v", v)
+		}
+	case llama.SamplerMinP, llama.SamplerTypical, llama.SamplerTailFree:
+		if v < 0 || v > 1 {
+			return fmt.Errorf("Sampler %s must be in [0, 1], got %v", t, v)
+		}
+	case llama.SamplerRepeatPenalty, llama.SamplerTemp:
+		if v <= 0 {
+			return fmt.Errorf("Sampler %s must be positive, got %v", t, v)
+		}
+	}
+	return nil
+}
+
+// checkSamplerChain checks the stages of a configured chain, stages
+// without a value are checked with the request parameters.
+func checkSamplerChain(chain []string) error {
+	if len(chain) > maxSamplers {
+		return fmt.Errorf("Too many samplers, at most %d are allowed", maxSamplers)
+	}
+	for _, s := range chain {
+		if _, _, err := parseSamplerStage(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// samplerChain returns the sampler stages of the request. Stages without
+// a value take it from the parameter of the same sampler, like top_k.
+func (p *CompletionParams) samplerChain() ([]llama.SamplerStage, error) {
+	if err := checkSamplerChain(p.Samplers); err != nil {
+		return nil, err
+	}
+	var ret []llama.SamplerStage
+	for _, s := range p.Samplers {
+		t, value, _ := parseSamplerStage(s)
+		stage := llama.SamplerStage{Type: t}
+		if value != nil {
+			stage.Value = *value
+		} else {
+			stage.Value = p.samplerParam(t)
+			if err := checkSamplerValue(t, stage.Value); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, stage)
+	}
+	return ret, nil
+}
+
+func (p *CompletionParams) samplerParam(t llama.SamplerType) float32 {
+	switch t {
+	case llama.SamplerRepeatPenalty:
+		return p.RepeatPenalty
+	case llama.SamplerTopK:
+		return float32(p.TopK)
+	case llama.SamplerTopP:
+		return p.TopP
+	case llama.SamplerMinP:
+		return p.MinP
+	case llama.SamplerTypical:
+		return p.TypicalP
+	case llama.SamplerTailFree:
+		return p.TfsZ
+	case llama.SamplerTemp:
+		return p.Temp
+	}
+	return 0
+}
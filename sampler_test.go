@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+func TestSamplerChain(t *testing.T) {
+	p := &CompletionParams{TopK: 40, Temp: 0.8, RepeatPenalty: 1.3, TypicalP: 0.9}
+	p.Samplers = []string{"repeat_penalty", "top_k=20", " typical ", "temp"}
+	got, err := p.samplerChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []llama.SamplerStage{
+		{Type: llama.SamplerRepeatPenalty, Value: 1.3},
+		{Type: llama.SamplerTopK, Value: 20},
+		{Type: llama.SamplerTypical, Value: 0.9},
+		{Type: llama.SamplerTemp, Value: 0.8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chain = %+v, want %+v", got, want)
+	}
+
+	invalid := [][]string{
+		{"top_x"},
+		{"top_k=1.5"},
+		{"top_p=0"},
+		{"temp=abc"},
+		{"min_p=2"},
+		{"top_p"}, // the request top_p is zero
+	}
+	for _, chain := range invalid {
+		p.Samplers = chain
+		if _, err := p.samplerChain(); err == nil {
+			t.Errorf("chain %q: want error", chain)
+		}
+	}
+}
+
+func TestSamplerPreset(t *testing.T) {
+	presets := map[string][]string{"greedy": {"top_k=1"}}
+	p := &CompletionParams{Prompt: "x", Tokens: 1, TopK: 40, TopP: 0.95, Temp: 0.8, RepeatPenalty: 1.3, SamplerPreset: "greedy"}
+	if err := validateCompletion(p, presets, Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	pp := p.ToPredictParams(1)
+	if want := []llama.SamplerStage{{Type: llama.SamplerTopK, Value: 1}}; !reflect.DeepEqual(pp.Samplers, want) {
+		t.Errorf("samplers = %+v, want %+v", pp.Samplers, want)
+	}
+
+	p.SamplerPreset = "unknown"
+	if err := validateCompletion(p, presets, Limits{}); err == nil {
+		t.Error("unknown preset: want error")
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/cornelk/llama-go/llama"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -13,9 +21,22 @@ import (
 
 type APIServer struct {
 	Seed       int
-	WorkerMgr  *WorkerManager
+	Pools      *WorkerPools
 	Listen     string
 	StaticPath string
+	TLS        *tls.Config
+	Defaults   CompletionDefaults
+	Limits     Limits
+	APIKeys    APIKeys
+	AdminKeys  APIKeys
+	srv        *http.Server
+
+	// WebSocket connections are hijacked, so srv.Shutdown does not wait
+	// for them. The value of wsConns tells if a job is running.
+	wsLock    sync.Mutex
+	wsConns   map[*websocket.Conn]bool
+	wsClosing bool
+	wsActive  sync.WaitGroup
 }
 
 func respJson(c *gin.Context, code int, data any) {
@@ -26,19 +47,115 @@ func respJsonErr(c *gin.Context, err error) {
 	respJsonErrStr(c, err.Error())
 }
 
+// respJobErr responds 503 if the job could not run because of the server
+// state, so that clients can retry.
+func respJobErr(c *gin.Context, err error) {
+	if errors.Is(err, ErrShuttingDown) || errors.Is(err, ErrNoWorker) {
+		respJson(c, 503, gin.H{
+			"Error": err.Error(),
+		})
+		return
+	}
+	respJsonErr(c, err)
+}
+
 func respJsonErrStr(c *gin.Context, msg string) {
 	respJson(c, 400, gin.H{
 		"Error": msg,
 	})
 }
 
-func (s *APIServer) Run() {
+func (s *APIServer) Run() error {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 	s.setupRouter(r)
 
-	log.Println("[API Server] Starting at", s.Listen)
-	r.Run(s.Listen)
+	lis, err := listen(s.Listen)
+	if err != nil {
+		return err
+	}
+	if s.TLS != nil {
+		lis = tls.NewListener(lis, s.TLS)
+		log.Println("[API Server] Starting with TLS at", s.Listen)
+	} else {
+		log.Println("[API Server] Starting at", s.Listen)
+	}
+	s.srv = &http.Server{
+		Handler: r,
+	}
+	err = s.srv.Serve(lis)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting new connections and waits for active requests
+// until ctx is done. Idle WebSocket connections are closed, the others
+// after their running job.
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	s.wsLock.Lock()
+	s.wsClosing = true
+	for conn, busy := range s.wsConns {
+		if !busy {
+			wsWriteClose(conn)
+			conn.Close()
+		}
+	}
+	s.wsLock.Unlock()
+
+	var err error
+	if s.srv != nil {
+		err = s.srv.Shutdown(ctx)
+	}
+	closed := make(chan struct{})
+	go func() {
+		s.wsActive.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// wsTrack adds a WebSocket connection to the ones Shutdown waits for, it
+// returns false if the server is shutting down.
+func (s *APIServer) wsTrack(conn *websocket.Conn) bool {
+	s.wsLock.Lock()
+	defer s.wsLock.Unlock()
+	if s.wsClosing {
+		return false
+	}
+	if s.wsConns == nil {
+		s.wsConns = map[*websocket.Conn]bool{}
+	}
+	s.wsConns[conn] = false
+	s.wsActive.Add(1)
+	return true
+}
+
+func (s *APIServer) wsUntrack(conn *websocket.Conn) {
+	s.wsLock.Lock()
+	delete(s.wsConns, conn)
+	s.wsLock.Unlock()
+	s.wsActive.Done()
+}
+
+// wsSetBusy marks if a job runs on the connection, it returns false if the
+// server is shutting down.
+func (s *APIServer) wsSetBusy(conn *websocket.Conn, busy bool) bool {
+	s.wsLock.Lock()
+	defer s.wsLock.Unlock()
+	if s.wsClosing {
+		return false
+	}
+	s.wsConns[conn] = busy
+	return true
 }
 
 func (s *APIServer) setupRouter(r *gin.Engine) {
@@ -46,18 +163,34 @@ func (s *APIServer) setupRouter(r *gin.Engine) {
 	r.Use(gzip.Gzip(gzip.DefaultCompression))
 	r.NoRoute(gin.WrapH(http.FileServer(gin.Dir(s.StaticPath, false))))
 	ar := r.Group("/api")
+	if len(s.APIKeys) > 0 {
+		// Admin keys can call the normal API too
+		keys := append(APIKeys{}, s.APIKeys...)
+		keys = append(keys, s.AdminKeys...)
+		ar.Use(keys.GinMiddleware())
+	}
 	ar.GET("/", s.Help)
 	ar.POST("/completion", s.Completion)
 	ar.GET("/tokenize", s.TokenizePrompt)
+	ar.GET("/models", s.ListModels)
 	ar.GET("/ws/completion", s.StreamCompletion)
+	adm := ar.Group("/admin")
+	adm.Use(s.adminMiddleware())
+	adm.GET("/pools/:name", s.PoolStatus)
+	adm.POST("/pools/:name/model", s.SwapModel)
+	adm.POST("/pools/:name/perplexity", s.Perplexity)
 }
 
 func (s *APIServer) Help(c *gin.Context) {
 	respJson(c, 200, gin.H{
-		"/api/":              "Help",
-		"/api/completion":    "Completion",
-		"/api/tokenize":      "Tokenize prompt",
-		"/api/ws/completion": "Completion web socket",
+		"/api/":                             "Help",
+		"/api/completion":                   "Completion",
+		"/api/tokenize":                     "Tokenize prompt",
+		"/api/models":                       "List models",
+		"/api/ws/completion":                "Completion web socket",
+		"/api/admin/pools/:name":            "Model pool status",
+		"/api/admin/pools/:name/model":      "Swap model of pool",
+		"/api/admin/pools/:name/perplexity": "Perplexity of a text on the model of pool",
 	})
 }
 
@@ -67,9 +200,18 @@ func (s *APIServer) TokenizePrompt(c *gin.Context) {
 		respJsonErrStr(c, "Require prompt")
 		return
 	}
-	pp := DefaultPredictParams(512)
+	if err := s.Limits.CheckPrompt(prompt); err != nil {
+		respJsonErr(c, err)
+		return
+	}
+	wm, err := s.Pools.Get(c.Query("model"))
+	if err != nil {
+		respJsonErr(c, err)
+		return
+	}
+	pp := llama.DefaultPredictParams(512)
 	job := NewJob(TokenizeJob, prompt, pp)
-	s.WorkerMgr.DispatchJob(job)
+	wm.DispatchJob(job)
 	var resp []string
 	for words := range job.Response {
 		resp = words
@@ -88,7 +230,138 @@ func (s *APIServer) TokenizePrompt(c *gin.Context) {
 	})
 }
 
+// adminMiddleware only allows requests with an admin key. The admin API is
+// disabled if no admin key is configured.
+func (s *APIServer) adminMiddleware() gin.HandlerFunc {
+	if len(s.AdminKeys) == 0 {
+		return func(c *gin.Context) {
+			c.AbortWithStatusJSON(403, gin.H{
+				"Error": "Admin API is disabled, set auth.admin_keys to enable",
+			})
+		}
+	}
+	return s.AdminKeys.GinMiddleware()
+}
+
+func (s *APIServer) PoolStatus(c *gin.Context) {
+	wm, err := s.Pools.Get(c.Param("name"))
+	if err != nil {
+		respJson(c, 404, gin.H{
+			"Error": err.Error(),
+		})
+		return
+	}
+	respJson(c, 200, wm.ModelInfo())
+}
+
+type SwapModelParams struct {
+	Path string `json:"path"`
+}
+
+func (s *APIServer) SwapModel(c *gin.Context) {
+	wm, err := s.Pools.Get(c.Param("name"))
+	if err != nil {
+		respJson(c, 404, gin.H{
+			"Error": err.Error(),
+		})
+		return
+	}
+	params := &SwapModelParams{}
+	err = c.BindJSON(params)
+	if err != nil {
+		respJsonErr(c, err)
+		return
+	}
+	if params.Path == "" {
+		respJsonErrStr(c, "Require path")
+		return
+	}
+	err = wm.SwapModel(params.Path)
+	if err != nil {
+		respJsonErr(c, err)
+		return
+	}
+	respJson(c, 202, wm.ModelInfo())
+}
+
+type PerplexityParams struct {
+	Text string `json:"text"`
+}
+
+type PerplexityResponse struct {
+	llama.PerplexityChunk
+	Finish bool   `json:"finish"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (r PerplexityResponse) Encode() []byte {
+	ret, _ := json.Marshal(r)
+	ret = append(ret, '\n')
+	return ret
+}
+
+// Perplexity streams the running perplexity after each chunk of the text.
+// It is an admin API as the text is not limited and evaluating it can take
+// a long time.
+func (s *APIServer) Perplexity(c *gin.Context) {
+	wm, err := s.Pools.Get(c.Param("name"))
+	if err != nil {
+		respJson(c, 404, gin.H{
+			"Error": err.Error(),
+		})
+		return
+	}
+	params := &PerplexityParams{}
+	err = c.BindJSON(params)
+	if err != nil {
+		respJsonErr(c, err)
+		return
+	}
+	if params.Text == "" {
+		respJsonErrStr(c, "Require text")
+		return
+	}
+	job := NewJob(PerplexityJob, params.Text, llama.DefaultPredictParams(0))
+	wm.DispatchJob(job)
+	var last llama.PerplexityChunk
+	c.Stream(func(w io.Writer) bool {
+		data, ok := <-job.Response
+		if !ok {
+			resp := PerplexityResponse{
+				PerplexityChunk: last,
+				Finish:          true,
+			}
+			if job.Err != nil {
+				resp.Error = job.Err.Error()
+			} else {
+				resp.Perplexity = job.Perplexity
+			}
+			w.Write(resp.Encode())
+			return false
+		}
+		chunk, err := llama.ParsePerplexityChunk(data)
+		if err != nil {
+			log.Println("Invalid perplexity chunk:", err)
+			return true
+		}
+		last = chunk
+		w.Write(PerplexityResponse{PerplexityChunk: chunk}.Encode())
+		return true
+	})
+	// Drain the job if the client went away
+	for range job.Response {
+	}
+}
+
+func (s *APIServer) ListModels(c *gin.Context) {
+	respJson(c, 200, gin.H{
+		"Default": s.Pools.DefaultName(),
+		"Models":  s.Pools.ModelInfos(),
+	})
+}
+
 type CompletionParams struct {
+	Model         string  `json:"model,omitempty"`
 	Prompt        string  `json:"prompt"`
 	Tokens        int     `json:"tokens"`
 	TopK          int     `json:"top_k,omitempty"`
@@ -96,11 +369,49 @@ type CompletionParams struct {
 	TopP          float32 `json:"top_p,omitempty"`
 	Temp          float32 `json:"temp,omitempty"`
 	RepeatPenalty float32 `json:"repeat_penalty,omitempty"`
-	Stream        bool    `json:"stream,omitempty"`
+	Mirostat      int     `json:"mirostat,omitempty"`
+	MirostatTau   float32 `json:"mirostat_tau,omitempty"`
+	MirostatEta   float32 `json:"mirostat_eta,omitempty"`
+	MinP          float32 `json:"min_p,omitempty"`
+	TypicalP      float32 `json:"typical_p,omitempty"`
+	TfsZ          float32 `json:"tfs_z,omitempty"`
+
+	FrequencyPenalty float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float32 `json:"presence_penalty,omitempty"`
+	// PenaltyExclude are token ids that are not penalized, like newline
+	PenaltyExclude []int `json:"penalty_exclude,omitempty"`
+
+	// NumBeams above one runs a beam search instead of sampling
+	NumBeams       int     `json:"num_beams,omitempty"`
+	NumReturnBeams int     `json:"num_return_beams,omitempty"`
+	LengthPenalty  float32 `json:"length_penalty,omitempty"`
+	EarlyStopping  bool    `json:"early_stopping,omitempty"`
+
+	// CFGScale above one steers the generation away from NegativePrompt
+	// with classifier-free guidance
+	NegativePrompt string  `json:"negative_prompt,omitempty"`
+	CFGScale       float32 `json:"cfg_scale,omitempty"`
+
+	// Instruct wraps the prompt in the "### Instruction:" and "### Response:"
+	// markers of Alpaca models and stops at the next instruction
+	Instruct bool `json:"instruct,omitempty"`
+
+	// ContextShift keeps generating once the context is full, the first
+	// ContextKeep tokens and the most recent half of the others are kept
+	ContextShift bool `json:"context_shift,omitempty"`
+	ContextKeep  int  `json:"context_keep,omitempty"`
+
+	// Samplers is the ordered sampler chain like ["top_k=20", "temp"],
+	// SamplerPreset selects a chain of the config instead
+	Samplers      []string `json:"samplers,omitempty"`
+	SamplerPreset string   `json:"sampler_preset,omitempty"`
+	Stream        bool     `json:"stream,omitempty"`
 }
 
-func (p *CompletionParams) ToPredictParams(seed int) PredictParams {
-	return PredictParams{
+func (p *CompletionParams) ToPredictParams(seed int) llama.PredictParams {
+	// the chain is checked by validateCompletion
+	samplers, _ := p.samplerChain()
+	return llama.PredictParams{
 		Seed:          seed,
 		Tokens:        p.Tokens,
 		RepeatLastN:   p.RepeatLastN,
@@ -109,13 +420,128 @@ func (p *CompletionParams) ToPredictParams(seed int) PredictParams {
 		Temp:          p.Temp,
 		RepeatPenalty: p.RepeatPenalty,
 		NBatch:        8,
+		Mirostat:      p.Mirostat,
+		MirostatTau:   p.MirostatTau,
+		MirostatEta:   p.MirostatEta,
+		MinP:          p.MinP,
+		TypicalP:      p.TypicalP,
+		TfsZ:          p.TfsZ,
+		Samplers:      samplers,
+
+		FrequencyPenalty: p.FrequencyPenalty,
+		PresencePenalty:  p.PresencePenalty,
+		PenaltyExclude:   p.PenaltyExclude,
+
+		NumBeams:       p.NumBeams,
+		NumReturnBeams: p.NumReturnBeams,
+		LengthPenalty:  p.LengthPenalty,
+		EarlyStopping:  p.EarlyStopping,
+
+		NegativePrompt: p.NegativePrompt,
+		CFGScale:       p.CFGScale,
+		Instruct:       p.Instruct,
+		ContextShift:   p.ContextShift,
+		ContextKeep:    p.ContextKeep,
+	}
+}
+
+// maxBeams limits the beams of a beam search, each beam keeps a copy of
+// the memory of its generated tokens.
+const maxBeams = 16
+
+// validateCompletion resolves the sampler preset and checks the required
+// parameters and the server limits.
+func validateCompletion(p *CompletionParams, presets map[string][]string, limits Limits) error {
+	if p.Prompt == "" {
+		return errors.New("Empty prompt")
+	}
+	if p.Tokens == 0 {
+		return errors.New("Tokens is zero")
+	}
+	if p.Temp <= 0 {
+		return fmt.Errorf("Invalid temp %v, must be positive", p.Temp)
+	}
+	if p.TopK <= 0 {
+		return fmt.Errorf("Invalid top_k %d, must be positive", p.TopK)
+	}
+	if p.TopP <= 0 || p.TopP > 1 {
+		return fmt.Errorf("Invalid top_p %v, must be in (0, 1]", p.TopP)
+	}
+	if p.RepeatPenalty <= 0 {
+		return fmt.Errorf("Invalid repeat_penalty %v, must be positive", p.RepeatPenalty)
+	}
+	if p.Mirostat < 0 || p.Mirostat > 2 {
+		return fmt.Errorf("Invalid mirostat version %d, must be 0, 1 or 2", p.Mirostat)
+	}
+	if p.MirostatTau < 0 || p.MirostatEta < 0 {
+		return errors.New("Mirostat tau and eta must not be negative")
 	}
+	if p.MinP < 0 || p.MinP > 1 {
+		return fmt.Errorf("Invalid min_p %v, must be in [0, 1]", p.MinP)
+	}
+	if p.TypicalP < 0 || p.TypicalP > 1 {
+		return fmt.Errorf("Invalid typical_p %v, must be in [0, 1]", p.TypicalP)
+	}
+	if p.TfsZ < 0 || p.TfsZ > 1 {
+		return fmt.Errorf("Invalid tfs_z %v, must be in [0, 1]", p.TfsZ)
+	}
+	if p.FrequencyPenalty < -2 || p.FrequencyPenalty > 2 {
+		return fmt.Errorf("Invalid frequency_penalty %v, must be in [-2, 2]", p.FrequencyPenalty)
+	}
+	if p.PresencePenalty < -2 || p.PresencePenalty > 2 {
+		return fmt.Errorf("Invalid presence_penalty %v, must be in [-2, 2]", p.PresencePenalty)
+	}
+	for _, id := range p.PenaltyExclude {
+		if id < 0 {
+			return fmt.Errorf("Invalid token id %d in penalty_exclude", id)
+		}
+	}
+	if p.NumBeams < 0 || p.NumBeams > maxBeams {
+		return fmt.Errorf("Invalid num_beams %d, must be in [0, %d]", p.NumBeams, maxBeams)
+	}
+	if p.NumReturnBeams < 0 || p.NumReturnBeams > p.NumBeams && p.NumReturnBeams > 1 {
+		return fmt.Errorf("Invalid num_return_beams %d, must not exceed num_beams", p.NumReturnBeams)
+	}
+	if p.CFGScale < 0 {
+		return fmt.Errorf("Invalid cfg_scale %v, must not be negative", p.CFGScale)
+	}
+	if p.NegativePrompt != "" && p.CFGScale <= 1 {
+		return errors.New("negative_prompt requires a cfg_scale above 1")
+	}
+	if p.CFGScale > 1 && p.NumBeams > 1 {
+		return errors.New("cfg_scale cannot be combined with a beam search")
+	}
+	if p.Instruct && p.NumBeams > 1 {
+		return errors.New("instruct cannot be combined with a beam search")
+	}
+	if p.ContextKeep < 0 {
+		return fmt.Errorf("Invalid context_keep %d, must not be negative", p.ContextKeep)
+	}
+	if p.ContextShift && (p.NumBeams > 1 || p.CFGScale > 1) {
+		return errors.New("context_shift cannot be combined with a beam search or cfg_scale")
+	}
+	if p.SamplerPreset != "" {
+		chain, ok := presets[p.SamplerPreset]
+		if !ok {
+			return fmt.Errorf("Unknown sampler preset %q", p.SamplerPreset)
+		}
+		p.Samplers = chain
+	}
+	if _, err := p.samplerChain(); err != nil {
+		return err
+	}
+	return limits.Check(p)
 }
 
 type StreamResponse struct {
-	Text   string `json:"text"`
-	Finish bool   `json:"finish"`
-	Reason string `json:"reason"`
+	Text   string        `json:"text"`
+	Finish bool          `json:"finish"`
+	Reason string        `json:"reason"`
+	Error  string        `json:"error,omitempty"`
+	Usage  *llama.Usage  `json:"usage,omitempty"`
+	Timing *llama.Timing `json:"timing,omitempty"`
+	Beams  []llama.Beam  `json:"beams,omitempty"`
+	Shifts int           `json:"context_shifts,omitempty"`
 }
 
 func (r StreamResponse) Encode() []byte {
@@ -132,22 +558,28 @@ func (s *APIServer) Completion(c *gin.Context) {
 		RepeatPenalty: 1.3,
 		RepeatLastN:   64,
 	}
+	s.Defaults.Apply(reqParams)
 	err := c.BindJSON(reqParams)
 	if err != nil {
 		respJsonErr(c, err)
 		return
 	}
-	if reqParams.Prompt == "" {
-		respJsonErrStr(c, "Empty prompt")
+	err = validateCompletion(reqParams, s.Defaults.SamplerPresets, s.Limits)
+	if err != nil {
+		respJsonErr(c, err)
 		return
 	}
-	if reqParams.Tokens == 0 {
-		respJsonErrStr(c, "Tokens is zero")
+	wm, err := s.Pools.Get(reqParams.Model)
+	if err != nil {
+		respJsonErr(c, err)
 		return
 	}
 	pp := reqParams.ToPredictParams(s.Seed)
 	job := NewJob(CompletionJob, reqParams.Prompt, pp)
-	s.WorkerMgr.DispatchJob(job)
+	wm.DispatchJob(job)
+	// Stop generating when the client goes away
+	stop := job.CancelOnDone(c.Request.Context())
+	defer stop()
 	if reqParams.Stream {
 		c.Stream(func(w io.Writer) bool {
 			output, ok := <-job.Response
@@ -156,6 +588,13 @@ func (s *APIServer) Completion(c *gin.Context) {
 					Text:   "",
 					Finish: true,
 					Reason: job.Reason,
+					Usage:  &job.Usage,
+					Timing: &job.Timing,
+					Beams:  job.Beams,
+					Shifts: job.ContextShifts,
+				}
+				if job.Err != nil {
+					resp.Error = job.Err.Error()
 				}
 				w.Write(resp.Encode())
 				return false
@@ -168,23 +607,33 @@ func (s *APIServer) Completion(c *gin.Context) {
 			w.Write(resp.Encode())
 			return true
 		})
+		// Drain the job if the client went away
+		for range job.Response {
+		}
 	} else {
 		resp := ""
-		tokens := 0
 		for word := range job.Response {
 			resp += word[0]
-			tokens += 1
 		}
 		if job.Err != nil {
-			respJsonErr(c, err)
+			respJobErr(c, job.Err)
 			return
 		}
-		respJson(c, 200, gin.H{
+		ret := gin.H{
 			"Prompt":         reqParams.Prompt,
 			"Text":           resp,
-			"Tokens":         tokens,
+			"Tokens":         job.Usage.CompletionTokens,
+			"Usage":          job.Usage,
+			"Timing":         job.Timing,
 			"CompleteReason": job.Reason,
-		})
+		}
+		if len(job.Beams) > 0 {
+			ret["Beams"] = job.Beams
+		}
+		if job.ContextShifts > 0 {
+			ret["ContextShifts"] = job.ContextShifts
+		}
+		respJson(c, 200, ret)
 	}
 }
 
@@ -205,10 +654,16 @@ func (s *APIServer) StreamCompletion(c *gin.Context) {
 		return
 	}
 	defer conn.Close()
+	if !s.wsTrack(conn) {
+		wsWriteClose(conn)
+		return
+	}
+	defer s.wsUntrack(conn)
 	for {
 		tp, payload, err := conn.ReadMessage()
 		if err != nil {
-			if err != io.EOF {
+			// Shutdown closes idle connections
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
 				log.Println("Read got error:", err)
 			}
 			return
@@ -230,30 +685,37 @@ func (s *APIServer) StreamCompletion(c *gin.Context) {
 			RepeatPenalty: 1.3,
 			RepeatLastN:   64,
 		}
+		s.Defaults.Apply(reqParams)
 		err = json.Unmarshal(payload, reqParams)
 		if err != nil {
 			log.Println("Bad Request:", err)
 			return
 		}
-		if reqParams.Prompt == "" {
-			err = wsWriteErr(conn, "Empty prompt")
+		err = validateCompletion(reqParams, s.Defaults.SamplerPresets, s.Limits)
+		if err != nil {
+			err = wsWriteErr(conn, err.Error())
 			if err != nil {
 				log.Println("Write web socket got error", err)
 				return
 			}
 			continue
 		}
-		if reqParams.Tokens == 0 {
-			err = wsWriteErr(conn, "Tokens is zero")
+		wm, err := s.Pools.Get(reqParams.Model)
+		if err != nil {
+			err = wsWriteErr(conn, err.Error())
 			if err != nil {
 				log.Println("Write web socket got error", err)
 				return
 			}
 			continue
 		}
+		if !s.wsSetBusy(conn, true) {
+			// Closed by Shutdown
+			return
+		}
 		pp := reqParams.ToPredictParams(s.Seed)
 		job := NewJob(CompletionJob, reqParams.Prompt, pp)
-		s.WorkerMgr.DispatchJob(job)
+		wm.DispatchJob(job)
 		for word := range job.Response {
 			rmsg := WsResponseMsg{
 				Text:   word[0],
@@ -264,6 +726,10 @@ func (s *APIServer) StreamCompletion(c *gin.Context) {
 			err = wsWriteResp(conn, rmsg)
 			if err != nil {
 				log.Println("Write web socket got error", err)
+				// Drain the job so the worker client is not blocked
+				job.Cancel()
+				for range job.Response {
+				}
 				return
 			}
 		}
@@ -276,20 +742,32 @@ func (s *APIServer) StreamCompletion(c *gin.Context) {
 			Error:  errMsg,
 			Reason: job.Reason,
 			Finish: true,
+			Usage:  &job.Usage,
+			Timing: &job.Timing,
+			Beams:  job.Beams,
+			Shifts: job.ContextShifts,
 		}
 		err = wsWriteResp(conn, rmsg)
 		if err != nil {
 			log.Println("Write web socket got error", err)
 			return
 		}
+		if !s.wsSetBusy(conn, false) {
+			wsWriteClose(conn)
+			return
+		}
 	}
 }
 
 type WsResponseMsg struct {
-	Text   string `json:"text"`
-	Error  string `json:"error"`
-	Reason string `json:"reason"`
-	Finish bool   `json:"finish"`
+	Text   string        `json:"text"`
+	Error  string        `json:"error"`
+	Reason string        `json:"reason"`
+	Finish bool          `json:"finish"`
+	Usage  *llama.Usage  `json:"usage,omitempty"`
+	Timing *llama.Timing `json:"timing,omitempty"`
+	Beams  []llama.Beam  `json:"beams,omitempty"`
+	Shifts int           `json:"context_shifts,omitempty"`
 }
 
 func (m WsResponseMsg) Encode() []byte {
@@ -297,6 +775,12 @@ func (m WsResponseMsg) Encode() []byte {
 	return ret
 }
 
+// wsWriteClose tells the client that the server is shutting down.
+func wsWriteClose(conn *websocket.Conn) error {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server is shutting down")
+	return conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
 func wsWriteErr(conn *websocket.Conn, msg string) error {
 	rmsg := WsResponseMsg{
 		Text:   "",
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,29 +12,83 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 	"unicode/utf8"
+
+	"github.com/cornelk/llama-go/llama"
+)
+
+var (
+	ErrShuttingDown = errors.New("Server is shutting down")
+	ErrNoWorker     = errors.New("No available worker")
 )
 
 var (
 	CompletionJob = "completion"
 	TokenizeJob   = "tokenize"
+	EmbedJob      = "embed"
+	PerplexityJob = "perplexity"
+	// CancelJob stops the running job of the worker connection
+	CancelJob = "cancel"
 )
 
 type Job struct {
-	Job      string
-	Prompt   string
-	Params   PredictParams
-	Response chan []string
-	Reason   string
-	Err      error
+	Job       string
+	Prompt    string
+	Params    llama.PredictParams
+	Response  chan []string
+	Embedding []float32
+	Usage     llama.Usage
+	Timing    llama.Timing
+	// Beams of a beam search, the best first
+	Beams []llama.Beam
+	// ContextShifts is the number of times the context was shifted
+	ContextShifts int
+	// Final perplexity of a perplexity job, the running values of each
+	// chunk are sent to Response.
+	Perplexity float64
+	Reason     string
+	Err        error
+	queued     time.Time
+	done       func()
+
+	cancel     chan struct{}
+	cancelOnce sync.Once
 }
 
-func NewJob(job string, prompt string, params PredictParams) *Job {
+func NewJob(job string, prompt string, params llama.PredictParams) *Job {
 	return &Job{
 		Job:      job,
 		Prompt:   prompt,
 		Params:   params,
 		Response: make(chan []string, 128),
+		cancel:   make(chan struct{}),
+	}
+}
+
+// Cancel stops the job, a completion finishes with the Cancel reason and
+// the text generated so far.
+func (j *Job) Cancel() {
+	j.cancelOnce.Do(func() {
+		close(j.cancel)
+	})
+}
+
+// CancelOnDone cancels the job once ctx is done, until stop is called.
+func (j *Job) CancelOnDone(ctx context.Context) (stop func()) {
+	quit := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			j.Cancel()
+		case <-quit:
+		}
+	}()
+	return func() {
+		close(quit)
 	}
 }
 
@@ -41,19 +96,29 @@ func (j *Job) Finish(reason string, err error) {
 	j.Reason = reason
 	j.Err = err
 	close(j.Response)
+	if j.done != nil {
+		j.done()
+	}
 }
 
 type workerJob struct {
-	params *workerRequest
-	respCh chan []string
-	err    error
-	reason FinishReason
+	ctx        context.Context
+	params     *workerRequest
+	respCh     chan []string
+	embedding  []float32
+	usage      llama.Usage
+	timing     *llama.Timing
+	beams      []llama.Beam
+	shifts     int
+	perplexity float64
+	err        error
+	reason     llama.FinishReason
 }
 
 type workerRequest struct {
 	Job    string
 	Prompt string
-	PP     PredictParams
+	PP     llama.PredictParams
 }
 
 func (r workerRequest) Encode() []byte {
@@ -63,10 +128,16 @@ func (r workerRequest) Encode() []byte {
 }
 
 type workerResponse struct {
-	Text   []string
-	Finish bool
-	Reason string
-	Err    string
+	Text       []string
+	Embedding  []float32     `json:",omitempty"`
+	Usage      *llama.Usage  `json:",omitempty"`
+	Timing     *llama.Timing `json:",omitempty"`
+	Beams      []llama.Beam  `json:",omitempty"`
+	Shifts     int           `json:",omitempty"`
+	Perplexity float64       `json:",omitempty"`
+	Finish     bool
+	Reason     string
+	Err        string
 }
 
 func (r workerResponse) Encode() []byte {
@@ -76,31 +147,31 @@ func (r workerResponse) Encode() []byte {
 }
 
 type Worker struct {
-	Model    *GGMLModel
+	Backend  Backend
 	sockFile string
 	jobCh    chan *workerJob
+	sock     net.Listener
 }
 
-func NewWorker(model *GGMLModel, fname string) *Worker {
+func NewWorker(backend Backend, fname string) *Worker {
 	return &Worker{
-		Model:    model,
+		Backend:  backend,
 		sockFile: fname,
 		jobCh:    make(chan *workerJob),
 	}
 }
 
 func (w *Worker) Run() error {
-	if _, err := os.Stat(w.sockFile); err == nil {
-		err = os.Remove(w.sockFile)
-		if err != nil {
-			return err
-		}
+	if err := removeStaleSocket(w.sockFile); err != nil {
+		return err
 	}
-	log.Println("Listen unix:", w.sockFile)
+	info := w.Backend.Info()
+	log.Printf("Listen unix: %s, %s backend %s", w.sockFile, info.Backend, info.Path)
 	sock, err := net.Listen("unix", w.sockFile)
 	if err != nil {
 		return err
 	}
+	w.sock = sock
 	// Start model worker
 	go w.startModelWorker()
 	for {
@@ -110,7 +181,14 @@ func (w *Worker) Run() error {
 		}
 		go w.handleConn(conn)
 	}
-	return nil
+}
+
+// Close stops accepting connections and removes the socket file.
+func (w *Worker) Close() error {
+	if w.sock == nil {
+		return nil
+	}
+	return w.sock.Close()
 }
 
 func (w *Worker) startModelWorker() {
@@ -119,10 +197,20 @@ func (w *Worker) startModelWorker() {
 	}
 }
 
+// handleConn runs the requests of a connection one after another. It keeps
+// reading while a request runs, a cancel request stops it.
 func (w *Worker) handleConn(conn net.Conn) {
 	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	cancel := func() {}
+	running := make(chan struct{})
+	close(running)
+	defer func() {
+		// Nobody reads the result of the running job anymore
+		cancel()
+		<-running
+	}()
 	for {
-		reader := bufio.NewReader(conn)
 		line, err := reader.ReadBytes('\n')
 		if err != nil {
 			log.Println("Cannot read connection:", err)
@@ -134,12 +222,26 @@ func (w *Worker) handleConn(conn net.Conn) {
 			log.Println("Cannot unmarshal parameter:", err)
 			return
 		}
-		w.handleRequest(conn, params)
+		if params.Job == CancelJob {
+			cancel()
+			continue
+		}
+		<-running
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done := make(chan struct{})
+		running = done
+		go func(ctx context.Context, cancel func()) {
+			defer close(done)
+			defer cancel()
+			w.handleRequest(ctx, conn, params)
+		}(ctx, cancel)
 	}
 }
 
-func (w *Worker) handleRequest(conn net.Conn, p *workerRequest) {
+func (w *Worker) handleRequest(ctx context.Context, conn net.Conn, p *workerRequest) {
 	job := &workerJob{
+		ctx:    ctx,
 		params: p,
 		respCh: make(chan []string, 128),
 	}
@@ -158,10 +260,16 @@ func (w *Worker) handleRequest(conn net.Conn, p *workerRequest) {
 		errMsg = job.err.Error()
 	}
 	item := workerResponse{
-		Text:   []string{},
-		Finish: true,
-		Err:    errMsg,
-		Reason: job.reason.String(),
+		Text:       []string{},
+		Embedding:  job.embedding,
+		Usage:      &job.usage,
+		Timing:     job.timing,
+		Beams:      job.beams,
+		Shifts:     job.shifts,
+		Perplexity: job.perplexity,
+		Finish:     true,
+		Err:        errMsg,
+		Reason:     job.reason.String(),
 	}
 	conn.Write(item.Encode())
 }
@@ -172,24 +280,58 @@ func (w *Worker) runJob(job *workerJob) {
 		w.runJobCompletion(job)
 	case TokenizeJob:
 		w.runJobTokenize(job)
+	case EmbedJob:
+		w.runJobEmbed(job)
+	case PerplexityJob:
+		w.runJobPerplexity(job)
 	default:
 		job.err = errors.New("Invalid job")
-		job.reason = PROMPT_ERR
+		job.reason = llama.PROMPT_ERR
 		close(job.respCh)
 	}
 }
 
 func (w *Worker) runJobTokenize(job *workerJob) {
-	ret := w.Model.TokenizePrompt(job.params.Prompt)
-	job.respCh <- ret
-	job.err = nil
-	job.reason = PROMPT_FINISH
+	ret, err := w.Backend.Tokenize(job.params.Prompt)
+	job.err = err
+	if err != nil {
+		job.reason = llama.PROMPT_ERR
+	} else {
+		job.respCh <- ret
+		job.reason = llama.PROMPT_FINISH
+	}
+	close(job.respCh)
+}
+
+func (w *Worker) runJobEmbed(job *workerJob) {
+	embedding, err := w.Backend.Embed(job.params.Prompt)
+	job.embedding = embedding
+	job.err = err
+	if err != nil {
+		job.reason = llama.PROMPT_ERR
+	} else {
+		job.reason = llama.PROMPT_FINISH
+	}
+	close(job.respCh)
+}
+
+func (w *Worker) runJobPerplexity(job *workerJob) {
+	ppl, err := w.Backend.Perplexity(job.params.Prompt, func(chunk llama.PerplexityChunk) {
+		job.respCh <- chunk.Strings()
+	})
+	job.perplexity = ppl
+	job.err = err
+	if err != nil {
+		job.reason = llama.PROMPT_ERR
+	} else {
+		job.reason = llama.PROMPT_FINISH
+	}
 	close(job.respCh)
 }
 
 func (w *Worker) runJobCompletion(job *workerJob) {
 	var buffer strings.Builder
-	reason, err := w.Model.Predict(job.params.PP, job.params.Prompt, func(word string) {
+	result, err := w.Backend.Predict(job.ctx, job.params.PP, job.params.Prompt, func(word string) {
 		buffer.WriteString(word)
 		bstr := buffer.String()
 		if utf8.ValidString(bstr) {
@@ -201,16 +343,36 @@ func (w *Worker) runJobCompletion(job *workerJob) {
 		job.respCh <- []string{buffer.String()}
 	}
 	job.err = err
-	job.reason = reason
+	job.reason = result.Reason
+	job.usage = result.Usage
+	job.timing = &result.Timing
+	job.beams = result.Beams
+	job.shifts = result.ContextShifts
 	close(job.respCh)
 }
 
+// How long a client waits before it tries again to connect a worker that
+// is loading or restarting
+const reconnectWait = 100 * time.Millisecond
+
+// How long to wait before restarting a crashed worker
+const restartWait = time.Second
+
 type workerClient struct {
 	id       int
 	sockFile string
-	start    bool
-	conn     net.Conn
-	jobCh    chan *Job
+	// start is set while the worker process is started or running
+	start atomic.Bool
+	// ready is set once the worker accepted a connection, a worker that
+	// exits before is not restarted as its model cannot be loaded.
+	ready   atomic.Bool
+	conn    net.Conn
+	jobCh   chan *Job
+	cmd     *exec.Cmd
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+	exited  chan struct{}
 }
 
 func (c *workerClient) ensureConn() (net.Conn, error) {
@@ -221,19 +383,80 @@ func (c *workerClient) ensureConn() (net.Conn, error) {
 			c.conn = nil
 			return nil, err
 		}
+		c.ready.Store(true)
 	}
 	return c.conn, nil
 }
 
+// reconnect replaces the connection and waits until the worker accepts it.
+// It gives up once the worker process is gone for good.
+func (c *workerClient) reconnect() (net.Conn, error) {
+	c.closeConn()
+	deadline := time.Now().Add(swapReadyTimeout)
+	for {
+		conn, err := c.ensureConn()
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-c.stop:
+			return nil, err
+		case <-c.exited:
+			return nil, err
+		case <-time.After(reconnectWait):
+		}
+	}
+}
+
+func (c *workerClient) closeConn() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
 func (c *workerClient) run() {
+	c.running = true
 	go func() {
-		for job := range c.jobCh {
-			c.processJob(job)
+		defer close(c.done)
+		for {
+			// Check stop first, a retired client should not take new jobs
+			select {
+			case <-c.stop:
+				return
+			default:
+			}
+			// Do not take jobs while the worker is loading or restarting
+			_, err := c.ensureConn()
+			if err != nil {
+				select {
+				case <-c.stop:
+					return
+				case <-time.After(reconnectWait):
+				}
+				continue
+			}
+			select {
+			case job := <-c.jobCh:
+				c.processJob(job)
+			case <-c.stop:
+				return
+			}
 		}
 	}()
 }
 
 func (c *workerClient) processJob(job *Job) {
+	queueWait := time.Since(job.queued)
+	select {
+	case <-job.cancel:
+		job.Finish(llama.PROMPT_CANCEL.String(), nil)
+		return
+	default:
+	}
 	conn, err := c.ensureConn()
 	if err != nil {
 		job.Finish("Error", err)
@@ -246,126 +469,284 @@ func (c *workerClient) processJob(job *Job) {
 	}
 	reqData := req.Encode()
 	_, err = conn.Write(reqData)
+	if err != nil {
+		// The worker restarted since the last job
+		conn, err = c.reconnect()
+		if err == nil {
+			_, err = conn.Write(reqData)
+		}
+	}
 	if err != nil {
 		job.Finish("Error", err)
-		conn.Close()
-		c.conn = nil
+		c.closeConn()
 		return
 	}
+	stopWatch := watchCancel(conn, job)
+	defer func() {
+		stopWatch()
+	}()
 	reader := bufio.NewReader(conn)
+	first := true
 	for {
 		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				// EOF just finish it
-				job.Finish("Error", io.EOF)
-				conn.Close()
-				c.conn = nil
+		if first && errors.Is(err, syscall.ECONNRESET) {
+			// A worker that was exiting accepted the connection and closed
+			// it without reading the request, send it to the new worker.
+			stopWatch()
+			stopWatch = func() {}
+			conn, err = c.reconnect()
+			if err == nil {
+				_, err = conn.Write(reqData)
+			}
+			if err != nil {
+				job.Finish("Error", err)
+				c.closeConn()
 				return
 			}
+			stopWatch = watchCancel(conn, job)
+			reader = bufio.NewReader(conn)
+			first = false
+			continue
+		}
+		first = false
+		if err != nil {
+			job.Finish("Error", fmt.Errorf("Read worker response: %w", err))
+			c.closeConn()
+			return
 		}
 		resp := new(workerResponse)
 		err = json.Unmarshal(line, resp)
 		if err != nil {
-			job.Finish("Error", err)
-			conn.Close()
-			c.conn = nil
+			job.Finish("Error", fmt.Errorf("Invalid worker response: %w", err))
+			c.closeConn()
 			return
 		}
 		if resp.Finish {
+			job.Embedding = resp.Embedding
+			job.Beams = resp.Beams
+			job.ContextShifts = resp.Shifts
+			job.Perplexity = resp.Perplexity
+			if resp.Usage != nil {
+				job.Usage = *resp.Usage
+			}
+			if resp.Timing != nil {
+				job.Timing = *resp.Timing
+				job.Timing.QueueMs = float64(queueWait.Microseconds()) / 1000
+				job.Timing.FirstTokenMs += job.Timing.QueueMs
+			}
 			if resp.Err == "" {
 				job.Finish(resp.Reason, nil)
 			} else {
-				job.Finish(resp.Reason, errors.New(resp.Err))
+				job.Finish(resp.Reason, workerErr(resp.Err))
 			}
 			return
 		} else {
 			job.Response <- resp.Text
 		}
 	}
-	// Actrually should not got there.
-	job.Finish("Error", errors.New("Buggy"))
 }
 
+// watchCancel sends a cancel request to the worker once the job is
+// canceled. stop waits for a pending write, so that it arrives before the
+// next request.
+func watchCancel(conn net.Conn, job *Job) (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-job.cancel:
+			req := workerRequest{Job: CancelJob}
+			conn.Write(req.Encode())
+		case <-quit:
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+// workerErr restores the request errors of the worker that are sent as
+// text, so the servers can report them as bad requests.
+func workerErr(msg string) error {
+	if strings.HasPrefix(msg, llama.ErrPromptTooLong.Error()) {
+		return fmt.Errorf("%w%s", llama.ErrPromptTooLong, strings.TrimPrefix(msg, llama.ErrPromptTooLong.Error()))
+	}
+	return errors.New(msg)
+}
+
+// Close stops taking new jobs and waits for the running job to finish.
 func (c *workerClient) Close() error {
-	close(c.jobCh)
-	return c.conn.Close()
+	close(c.stop)
+	if c.running {
+		<-c.done
+	}
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
 }
 
+// How long to wait for the workers of a new model to load
+const swapReadyTimeout = 10 * time.Minute
+
 type WorkerManager struct {
+	name       string
 	execFile   string
 	modelPath  string
+	backend    string
 	numWorkers int
 	ctxSize    int
 	nParts     int
 	threads    int
 	workers    []*workerClient
+	clients    map[*workerClient]struct{}
 	jobCh      chan *Job
 	debug      bool
+	lock       sync.Mutex
+	stopping   bool
+	gen        int
+	swapping   bool
+	swapErr    string
+	inflight   sync.WaitGroup
 }
 
-func NewWorkerManager(execFile string, modelPath string, numWorkers int, ctxSize int, nParts int, threads int, debug bool) *WorkerManager {
+func NewWorkerManager(name string, execFile string, modelPath string, backend string, numWorkers int, ctxSize int, nParts int, threads int, debug bool) *WorkerManager {
 	return &WorkerManager{
+		name:       name,
 		execFile:   execFile,
 		numWorkers: numWorkers,
 		modelPath:  modelPath,
+		backend:    backend,
 		ctxSize:    ctxSize,
 		nParts:     nParts,
 		threads:    threads,
-		workers:    make([]*workerClient, numWorkers),
+		clients:    map[*workerClient]struct{}{},
 		jobCh:      make(chan *Job),
 		debug:      debug,
 	}
 }
 
 func (m *WorkerManager) StartWorkers() error {
+	workers := m.spawnWorkers(m.gen, m.modelPath)
+	for _, client := range workers {
+		client.run()
+	}
+	m.lock.Lock()
+	m.workers = workers
+	m.lock.Unlock()
+	return nil
+}
+
+// spawnWorkers creates the clients and starts the worker processes of a
+// model. The clients do not take jobs before run is called.
+func (m *WorkerManager) spawnWorkers(gen int, modelPath string) []*workerClient {
+	workers := make([]*workerClient, m.numWorkers)
 	for i := 0; i < m.numWorkers; i++ {
-		sockFile := fmt.Sprintf("/tmp/ggml-worker.%d.sock", i)
-		if m.debug {
-			log.Printf("Start worker using below command:")
-			log.Printf("%s -M worker -t %d -m %s -S %s -c %d -n %d", m.execFile, m.threads, m.modelPath, sockFile, m.ctxSize, m.nParts)
-		} else {
-			go m.startWorkerProcess(i, sockFile)
-		}
+		sockFile := fmt.Sprintf("/tmp/ggml-worker.%s.%d.%d.sock", m.name, gen, i)
 		client := &workerClient{
 			id:       i,
 			sockFile: sockFile,
-			start:    true,
 			jobCh:    m.jobCh,
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+			exited:   make(chan struct{}),
+		}
+		client.start.Store(true)
+		workers[i] = client
+		m.lock.Lock()
+		m.clients[client] = struct{}{}
+		m.lock.Unlock()
+		if m.debug {
+			log.Printf("Start worker using below command:")
+			log.Printf("%s %s", m.execFile, strings.Join(m.workerArgs(sockFile, modelPath), " "))
+		} else {
+			go m.startWorkerProcess(client, modelPath)
 		}
-		client.run()
-		m.workers[i] = client
 	}
-	return nil
+	return workers
 }
 
-func (m *WorkerManager) startWorkerProcess(id int, sockFile string) {
+func (m *WorkerManager) startWorkerProcess(client *workerClient, modelPath string) {
+	defer close(client.exited)
 	execFile := m.execFile
+	id := client.id
 	for {
-		m.workers[id].start = false
-		log.Printf("Start Worker Process %d", id)
-		cmd := exec.Command(execFile,
-			"-M", "worker",
-			"-t", fmt.Sprintf("%d", m.threads),
-			"-m", m.modelPath,
-			"-S", sockFile,
-			"-c", fmt.Sprintf("%d", m.ctxSize),
-			"-n", fmt.Sprintf("%d", m.nParts),
-		)
+		m.lock.Lock()
+		if m.stopping || isClosed(client.stop) {
+			m.lock.Unlock()
+			return
+		}
+		client.start.Store(false)
+		log.Printf("Start Worker Process %s/%d", m.name, id)
+		cmd := exec.Command(execFile, m.workerArgs(client.sockFile, modelPath)...)
+		// Use own process group so that a Ctrl+C on the terminal does not
+		// kill workers before the master drains the running jobs.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 		stdout, err := cmd.StdoutPipe()
 		if err != nil {
 			log.Println("Cannot get stdout:", err)
-			m.workers[id].start = false
+			client.start.Store(false)
+			m.lock.Unlock()
 			return
 		}
 		go m.handleStdout(id, stdout)
-		m.workers[id].start = true
-		err = cmd.Run()
+		err = cmd.Start()
 		if err != nil {
 			log.Println("Start worker got error", err)
-			m.workers[id].start = false
+			client.start.Store(false)
+			m.lock.Unlock()
 			return
 		}
+		client.cmd = cmd
+		client.start.Store(true)
+		m.lock.Unlock()
+		err = cmd.Wait()
+		m.lock.Lock()
+		stopped := m.stopping || isClosed(client.stop)
+		m.lock.Unlock()
+		if stopped {
+			return
+		}
+		if err != nil {
+			log.Println("Start worker got error", err)
+			if !client.ready.Load() {
+				client.start.Store(false)
+				return
+			}
+			// Crashed after it was serving, restart it
+			client.ready.Store(false)
+			select {
+			case <-client.stop:
+				return
+			case <-time.After(restartWait):
+			}
+		}
+	}
+}
+
+func (m *WorkerManager) workerArgs(sockFile string, modelPath string) []string {
+	args := []string{
+		"-M", "worker",
+		"-t", fmt.Sprintf("%d", m.threads),
+		"-m", modelPath,
+		"-S", sockFile,
+		"-c", fmt.Sprintf("%d", m.ctxSize),
+		"-n", fmt.Sprintf("%d", m.nParts),
+	}
+	if m.backend != "" {
+		args = append(args, "-backend", m.backend)
+	}
+	return args
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -374,23 +755,229 @@ func (m *WorkerManager) handleStdout(id int, out io.ReadCloser) {
 		reader := bufio.NewReader(out)
 		line, _, err := reader.ReadLine()
 		if err != nil {
-			log.Printf("[Worker %d] Read Stdout got error: %v", id, err)
+			log.Printf("[Worker %s/%d] Read Stdout got error: %v", m.name, id, err)
 			break
 		}
-		log.Printf("[Worker %d] %s", id, string(line))
+		log.Printf("[Worker %s/%d] %s", m.name, id, string(line))
 	}
 	out.Close()
 }
 
 func (m *WorkerManager) DispatchJob(job *Job) {
-	for i := 0; i < m.numWorkers; i++ {
-		client := m.workers[i]
-		if !client.start {
+	m.lock.Lock()
+	if m.stopping {
+		m.lock.Unlock()
+		job.Finish("Error", ErrShuttingDown)
+		return
+	}
+	m.inflight.Add(1)
+	job.done = m.inflight.Done
+	job.queued = time.Now()
+	workers := m.workers
+	m.lock.Unlock()
+	for _, client := range workers {
+		if !client.start.Load() {
 			continue
 		}
 		m.jobCh <- job
 		return
 	}
 	// Means no worker available
-	job.Finish("Error", errors.New("No available worker"))
+	job.Finish("Error", ErrNoWorker)
+}
+
+// SwapModel starts workers on a new model file in background. Once they are
+// ready the traffic moves to them, and the old workers are stopped after
+// their running jobs finished.
+func (m *WorkerManager) SwapModel(modelPath string) error {
+	_, err := os.Stat(modelPath)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.stopping {
+		return ErrShuttingDown
+	}
+	if m.swapping {
+		return errors.New("Model swap is in progress")
+	}
+	m.swapping = true
+	m.swapErr = ""
+	m.gen++
+	go m.swapModel(m.gen, modelPath)
+	return nil
+}
+
+func (m *WorkerManager) swapModel(gen int, modelPath string) {
+	log.Printf("[Pool %s] Start workers for model %s", m.name, modelPath)
+	workers := m.spawnWorkers(gen, modelPath)
+	err := m.waitReady(workers)
+	m.lock.Lock()
+	if err == nil && m.stopping {
+		err = ErrShuttingDown
+	}
+	if err != nil {
+		m.swapping = false
+		m.swapErr = err.Error()
+		m.lock.Unlock()
+		log.Printf("[Pool %s] Swap model got error: %v", m.name, err)
+		m.retireWorkers(workers)
+		return
+	}
+	old := m.workers
+	m.workers = workers
+	m.modelPath = modelPath
+	for _, client := range workers {
+		client.run()
+	}
+	m.lock.Unlock()
+	log.Printf("[Pool %s] Traffic moved to model %s, stop old workers", m.name, modelPath)
+	m.retireWorkers(old)
+	m.lock.Lock()
+	m.swapping = false
+	m.lock.Unlock()
+	log.Printf("[Pool %s] Swap model finished", m.name)
+}
+
+// waitReady waits until every worker accepts connections, that is after
+// the model is loaded.
+func (m *WorkerManager) waitReady(workers []*workerClient) error {
+	deadline := time.Now().Add(swapReadyTimeout)
+	for _, client := range workers {
+		for {
+			conn, err := net.Dial("unix", client.sockFile)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				return errors.New("Timeout waiting for workers to be ready")
+			}
+			select {
+			case <-client.exited:
+				return fmt.Errorf("Worker %d exited before ready", client.id)
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+	}
+	return nil
+}
+
+// WaitReady waits until the started workers accept connections.
+func (m *WorkerManager) WaitReady() error {
+	m.lock.Lock()
+	workers := m.workers
+	m.lock.Unlock()
+	return m.waitReady(workers)
+}
+
+// retireWorkers lets the clients finish their running jobs and then stops
+// the worker processes.
+func (m *WorkerManager) retireWorkers(workers []*workerClient) {
+	for _, client := range workers {
+		client.Close()
+	}
+	m.stopProcesses(workers)
+}
+
+type ModelInfo struct {
+	Name       string
+	Path       string
+	CtxSize    int
+	NumWorkers int
+	Threads    int
+	Swapping   bool
+	SwapError  string `json:",omitempty"`
+}
+
+func (m *WorkerManager) ModelInfo() ModelInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return ModelInfo{
+		Name:       m.name,
+		Path:       m.modelPath,
+		CtxSize:    m.ctxSize,
+		NumWorkers: m.numWorkers,
+		Threads:    m.threads,
+		Swapping:   m.swapping,
+		SwapError:  m.swapErr,
+	}
+}
+
+// Shutdown rejects new jobs, waits for queued and running jobs until ctx is
+// done, then stops the worker processes and removes their socket files.
+func (m *WorkerManager) Shutdown(ctx context.Context) error {
+	m.lock.Lock()
+	m.stopping = true
+	m.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		m.inflight.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+		log.Println("All jobs finished")
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Println("Grace period exceeded, stop workers with running jobs")
+	}
+	m.stopWorkers()
+	return err
+}
+
+func (m *WorkerManager) stopWorkers() {
+	m.lock.Lock()
+	workers := make([]*workerClient, 0, len(m.clients))
+	for client := range m.clients {
+		workers = append(workers, client)
+	}
+	m.lock.Unlock()
+	m.stopProcesses(workers)
+}
+
+// stopProcesses terminates the worker processes and removes their socket
+// files. Processes not exited in 5 seconds are killed.
+func (m *WorkerManager) stopProcesses(workers []*workerClient) {
+	m.lock.Lock()
+	for _, client := range workers {
+		if client.cmd == nil || client.cmd.Process == nil {
+			continue
+		}
+		log.Printf("Stop Worker Process %s/%d", m.name, client.id)
+		client.cmd.Process.Signal(syscall.SIGTERM)
+	}
+	m.lock.Unlock()
+
+	for _, client := range workers {
+		if m.debug {
+			continue
+		}
+		select {
+		case <-client.exited:
+		case <-time.After(5 * time.Second):
+			m.lock.Lock()
+			if client.cmd != nil && client.cmd.Process != nil {
+				log.Printf("Kill Worker Process %s/%d", m.name, client.id)
+				client.cmd.Process.Kill()
+			}
+			m.lock.Unlock()
+			<-client.exited
+		}
+	}
+
+	m.lock.Lock()
+	for _, client := range workers {
+		delete(m.clients, client)
+	}
+	m.lock.Unlock()
+	for _, client := range workers {
+		err := os.Remove(client.sockFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Cannot remove socket file:", err)
+		}
+	}
 }
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cornelk/llama-go/client"
+	"github.com/cornelk/llama-go/llama"
+	"github.com/cornelk/llama-go/llamapb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startFakeWorker runs a worker with a fake backend on a socket in a temp
+// directory.
+func startFakeWorker(t *testing.T, script FakeScript) string {
+	t.Helper()
+	sockFile := filepath.Join(t.TempDir(), "worker.sock")
+	backend := NewFakeBackend("", 64, 1)
+	backend.Script = script
+	worker := NewWorker(backend, sockFile)
+	go worker.Run()
+	t.Cleanup(func() {
+		worker.Close()
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("unix", sockFile)
+		if err == nil {
+			conn.Close()
+			return sockFile
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("worker not ready: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readWorkerResponses(t *testing.T, conn net.Conn) []workerResponse {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	ret := []workerResponse{}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		resp := workerResponse{}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		ret = append(ret, resp)
+		if resp.Finish {
+			return ret
+		}
+	}
+}
+
+func TestWorkerProtocol(t *testing.T) {
+	sockFile := startFakeWorker(t, FakeScript{
+		FakeBehavior: FakeBehavior{Tokens: []string{" a", " b"}},
+	})
+	conn, err := net.Dial("unix", sockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req := workerRequest{Job: CompletionJob, Prompt: "Hello world", PP: llama.PredictParams{Tokens: 3}}
+	conn.Write(req.Encode())
+	resps := readWorkerResponses(t, conn)
+	if len(resps) != 4 {
+		t.Fatalf("got %d responses, want 3 words and the final one", len(resps))
+	}
+	text := ""
+	for _, resp := range resps[:3] {
+		text += strings.Join(resp.Text, "")
+	}
+	if text != " a b a" {
+		t.Errorf("text = %q", text)
+	}
+	final := resps[3]
+	if final.Reason != "Stop" || final.Err != "" || final.Usage == nil || final.Usage.CompletionTokens != 3 {
+		t.Errorf("final = %+v", final)
+	}
+
+	// The connection serves the next request
+	req = workerRequest{Job: TokenizeJob, Prompt: "Hello world"}
+	conn.Write(req.Encode())
+	resps = readWorkerResponses(t, conn)
+	if len(resps) != 2 || strings.Join(resps[0].Text, "|") != "|Hello| world" {
+		t.Errorf("tokenize = %+v", resps)
+	}
+
+	req = workerRequest{Job: "unknown"}
+	conn.Write(req.Encode())
+	resps = readWorkerResponses(t, conn)
+	if len(resps) != 1 || resps[0].Err != "Invalid job" || resps[0].Reason != "Error" {
+		t.Errorf("invalid job = %+v", resps)
+	}
+}
+
+func TestWorkerProtocolFailure(t *testing.T) {
+	sockFile := startFakeWorker(t, FakeScript{
+		FakeBehavior: FakeBehavior{FailAfter: intPtr(1), Error: "boom"},
+	})
+	conn, err := net.Dial("unix", sockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req := workerRequest{Job: CompletionJob, Prompt: "x", PP: llama.PredictParams{Tokens: 5}}
+	conn.Write(req.Encode())
+	resps := readWorkerResponses(t, conn)
+	final := resps[len(resps)-1]
+	if len(resps) != 2 || final.Err != "boom" || final.Reason != "Error" {
+		t.Errorf("responses = %+v", resps)
+	}
+}
+
+func TestWorkerClientProcessJob(t *testing.T) {
+	sockFile := startFakeWorker(t, FakeScript{
+		FakeBehavior: FakeBehavior{Tokens: []string{"x"}},
+	})
+	client := &workerClient{sockFile: sockFile, stop: make(chan struct{})}
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		job := NewJob(CompletionJob, "Hello", llama.PredictParams{Tokens: 4})
+		job.queued = time.Now()
+		client.processJob(job)
+		text := ""
+		for words := range job.Response {
+			text += strings.Join(words, "")
+		}
+		if job.Err != nil || job.Reason != "Stop" || text != "xxxx" {
+			t.Errorf("job %d: %q %v %v", i, text, job.Reason, job.Err)
+		}
+		if job.Usage.PromptTokens != 2 || job.Usage.CompletionTokens != 4 {
+			t.Errorf("job %d: usage = %+v", i, job.Usage)
+		}
+	}
+}
+
+func TestWorkerClientBeams(t *testing.T) {
+	sockFile := startFakeWorker(t, FakeScript{
+		FakeBehavior: FakeBehavior{Tokens: []string{" x"}},
+	})
+	client := &workerClient{sockFile: sockFile, stop: make(chan struct{})}
+	defer client.Close()
+
+	job := NewJob(CompletionJob, "Hello", llama.PredictParams{Tokens: 2, NumBeams: 4, NumReturnBeams: 2})
+	job.queued = time.Now()
+	client.processJob(job)
+	for range job.Response {
+	}
+	if job.Err != nil || len(job.Beams) != 2 {
+		t.Fatalf("beams = %+v, err = %v", job.Beams, job.Err)
+	}
+	if job.Beams[0].Text != " x x" || job.Beams[0].Score < job.Beams[1].Score {
+		t.Errorf("beams = %+v", job.Beams)
+	}
+}
+
+func TestWorkerClientContextShift(t *testing.T) {
+	sockFile := startFakeWorker(t, FakeScript{})
+	client := &workerClient{sockFile: sockFile, stop: make(chan struct{})}
+	defer client.Close()
+
+	// the context of 64 tokens is full after 62 tokens and again after
+	// the 31 tokens of the freed half
+	job := NewJob(CompletionJob, "Hello", llama.PredictParams{Tokens: 100, ContextShift: true})
+	job.queued = time.Now()
+	client.processJob(job)
+	for range job.Response {
+	}
+	if job.Err != nil || job.ContextShifts != 2 || job.Usage.CompletionTokens != 100 {
+		t.Errorf("shifts = %d, usage = %+v, err = %v", job.ContextShifts, job.Usage, job.Err)
+	}
+}
+
+func TestWorkerClientCancel(t *testing.T) {
+	sockFile := startFakeWorker(t, FakeScript{
+		FakeBehavior: FakeBehavior{Tokens: []string{" x"}, Delay: Duration(20 * time.Millisecond)},
+	})
+	client := &workerClient{sockFile: sockFile, stop: make(chan struct{})}
+	defer client.Close()
+
+	job := NewJob(CompletionJob, "Hello", llama.PredictParams{Tokens: 100})
+	job.queued = time.Now()
+	go func(job *Job) {
+		<-job.Response
+		job.Cancel()
+		for range job.Response {
+		}
+	}(job)
+	start := time.Now()
+	client.processJob(job)
+	if job.Err != nil || job.Reason != "Cancel" || job.Usage.CompletionTokens >= 100 {
+		t.Errorf("reason = %q, usage = %+v, err = %v", job.Reason, job.Usage, job.Err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancel took %v", elapsed)
+	}
+
+	// A canceled job does not affect the next one on the connection
+	job = NewJob(CompletionJob, "Hello", llama.PredictParams{Tokens: 2})
+	job.queued = time.Now()
+	client.processJob(job)
+	text := ""
+	for words := range job.Response {
+		text += strings.Join(words, "")
+	}
+	if job.Err != nil || job.Reason != "Stop" || text != " x x" {
+		t.Errorf("next job: %q %q %v", text, job.Reason, job.Err)
+	}
+
+	// A job canceled while queued does not run
+	job = NewJob(CompletionJob, "Hello", llama.PredictParams{Tokens: 2})
+	job.Cancel()
+	client.processJob(job)
+	for range job.Response {
+	}
+	if job.Err != nil || job.Reason != "Cancel" || job.Usage.CompletionTokens != 0 {
+		t.Errorf("queued job: %q %+v %v", job.Reason, job.Usage, job.Err)
+	}
+}
+
+// waitSwap waits until the model swap of the pool finished.
+func waitSwap(t *testing.T, wm *WorkerManager) ModelInfo {
+	t.Helper()
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		info := wm.ModelInfo()
+		if !info.Swapping {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("swap did not finish")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWorkerManagerSwap(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	newScript := filepath.Join(t.TempDir(), "new.yaml")
+	if err := os.WriteFile(newScript, []byte(`tokens: [" new"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The running job finishes on the old worker
+	job := NewJob(CompletionJob, "slow", llama.PredictParams{Tokens: 10})
+	wm.DispatchJob(job)
+	text := strings.Join(<-job.Response, "")
+	if err := wm.SwapModel(newScript); err != nil {
+		t.Fatal(err)
+	}
+	if err := wm.SwapModel(newScript); err == nil {
+		t.Error("second swap: want error while swapping")
+	}
+	for words := range job.Response {
+		text += strings.Join(words, "")
+	}
+	if job.Err != nil || job.Reason != "Stop" || text != strings.Repeat(" a b", 5) {
+		t.Errorf("in-flight job: got %q %q %v", text, job.Reason, job.Err)
+	}
+
+	info := waitSwap(t, wm)
+	if info.Path != newScript || info.SwapError != "" {
+		t.Errorf("info = %+v", info)
+	}
+	text, job = runJob(wm, CompletionJob, "Hello", 2)
+	if job.Err != nil || text != " new new" {
+		t.Errorf("after swap: got %q %v", text, job.Err)
+	}
+}
+
+func TestWorkerManagerSwapFailure(t *testing.T) {
+	wm := startPool(t, e2eScript, 1)
+	oldPath := wm.ModelInfo().Path
+	badScript := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(badScript, []byte("load_error: broken model\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wm.SwapModel(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing model: want error")
+	}
+	if err := wm.SwapModel(badScript); err != nil {
+		t.Fatal(err)
+	}
+	// The new workers cannot load, the pool keeps the old model
+	info := waitSwap(t, wm)
+	if info.Path != oldPath || !strings.Contains(info.SwapError, "exited before ready") {
+		t.Errorf("info = %+v", info)
+	}
+	text, job := runJob(wm, CompletionJob, "echo one", 2)
+	if job.Err != nil || text != " one one" {
+		t.Errorf("after failed swap: got %q %v", text, job.Err)
+	}
+}
+
+func TestWorkerPoolsUnknownModel(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := NewWorkerManager("chat", exe, "chat.bin", FakeBackendName, 1, 64, 1, 1, false)
+	instruct := NewWorkerManager("instruct", exe, "instruct.bin", FakeBackendName, 1, 64, 1, 1, false)
+	pools := NewWorkerPools("chat")
+	pools.Add("chat", chat)
+	pools.Add("instruct", instruct)
+
+	for name, want := range map[string]*WorkerManager{"": chat, "chat": chat, "instruct": instruct} {
+		if wm, err := pools.Get(name); err != nil || wm != want {
+			t.Errorf("Get(%q): wrong pool, err = %v", name, err)
+		}
+	}
+	if _, err := pools.Get("13B"); err == nil || err.Error() != `Unknown model "13B"` {
+		t.Errorf("Get(13B) err = %v", err)
+	}
+
+	// The servers reject the request before a job is dispatched
+	c := startServer(t, chat)
+	_, err = c.Complete(context.Background(), client.CompletionRequest{Model: "13B", Prompt: "Hello", Tokens: 2})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != `Unknown model "13B"` {
+		t.Errorf("HTTP err = %v", err)
+	}
+	g := startGRPCServer(t, chat)
+	stream, err := g.Complete(context.Background(), &llamapb.CompleteRequest{Model: "13B", Prompt: "Hello", Tokens: 2}, grpc.WaitForReady(true))
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("gRPC err = %v", err)
+	}
+}